@@ -0,0 +1,136 @@
+package webapp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// immutableCacheControl is set on fingerprinted assets, their content never changes under the same name
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprintLength is the number of hex characters of the content hash used in the file name
+const fingerprintLength = 8
+
+// Assets serves files under fingerprinted names, e.g. app.3f2a1c0b.js for app.js.
+// The fingerprints are computed from the file contents when the assets are registered.
+type Assets struct {
+	path string
+
+	// names maps the file names to the fingerprinted names, files the other way around
+	names map[string]string
+	files map[string]string
+}
+
+// Assets registers the files in fsys under their fingerprinted names with a far future
+// immutable Cache-Control header, the files are also served under their own names
+// as with StaticFS. Use AssetURL to link to the fingerprinted files.
+//
+//     assets, err := router.Assets("/assets", assetsFS)
+//     if err != nil {
+//         log.Fatal(err)
+//     }
+//     tmpl := template.New("").Funcs(template.FuncMap{"asset": assets.AssetURL})
+func (group *routeGroup) Assets(relativePath string, fsys fs.FS, options ...StaticOption) (*Assets, error) {
+	config := newStaticConfig(options)
+	assets, err := newAssets(fsys, config.hideDotfiles)
+	if err != nil {
+		return nil, err
+	}
+	assets.path = group.calculateAbsolutePath(relativePath)
+
+	fileSystem := http.FS(fsys)
+	staticHandler := group.createStaticHandler(fileSystem, config)
+
+	immutable := config
+	immutable.cacheControl = map[string]string{"": immutableCacheControl}
+
+	handler := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+Param(ctx, "filepath")), "/")
+		if file, ok := assets.files[name]; ok {
+			group.serveFile(ctx, rw, req, fileSystem, "/"+file, immutable)
+			return
+		}
+		staticHandler(ctx, rw, req)
+	}
+
+	relativePath = path.Join(relativePath, "/*filepath")
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+	return assets, nil
+}
+
+func newAssets(fsys fs.FS, hideDotfiles bool) (*Assets, error) {
+	assets := &Assets{
+		names: make(map[string]string),
+		files: make(map[string]string),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if hideDotfiles && name != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		fingerprint, err := fingerprintFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		extension := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, extension) + "." + fingerprint + extension
+		assets.names[name] = fingerprinted
+		assets.files[fingerprinted] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+func fingerprintFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:fingerprintLength], nil
+}
+
+// AssetURL returns the path of the fingerprinted file, files
+// without a fingerprint are returned under their own name
+func (assets *Assets) AssetURL(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if fingerprinted, ok := assets.names[name]; ok {
+		name = fingerprinted
+	}
+	return path.Join(assets.path, name)
+}
+
+// Manifest returns the file names mapped to their fingerprinted names
+func (assets *Assets) Manifest() map[string]string {
+	manifest := make(map[string]string, len(assets.names))
+	for name, fingerprinted := range assets.names {
+		manifest[name] = fingerprinted
+	}
+	return manifest
+}
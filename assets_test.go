@@ -0,0 +1,58 @@
+package webapp
+
+import (
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"html/template"
+	"strings"
+	"testing/fstest"
+)
+
+type AssetsSuite struct{}
+
+var _ = Suite(&AssetsSuite{})
+
+func (s *AssetsSuite) TestAssets(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	assets, err := rg.Group("/assets").Assets("/", testFS, StaticHideDotfiles())
+	c.Assert(err, IsNil)
+
+	url := assets.AssetURL("js/app.js")
+	c.Assert(url, Matches, `/assets/js/app\.[0-9a-f]{8}\.js`)
+	c.Assert(assets.AssetURL("/js/app.js"), Equals, url)
+	c.Assert(assets.AssetURL("js/missing.js"), Equals, "/assets/js/missing.js")
+
+	response := doTestRequest(rg, "GET", url)
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "app()")
+	c.Assert(response.Header().Get("Cache-Control"), Equals, "public, max-age=31536000, immutable")
+	c.Assert(response.Header().Get("Content-Type"), Matches, ".*javascript.*")
+
+	response = doTestRequest(rg, "GET", "/assets/js/app.js")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Header().Get("Cache-Control"), Equals, "")
+
+	c.Assert(doTestRequest(rg, "GET", "/assets/js/app.00000000.js").Code, Equals, 404)
+
+	_, ok := assets.Manifest()[".env"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *AssetsSuite) TestAssetFingerprintChangesWithContent(c *C) {
+	first, err := newAssets(fstest.MapFS{"app.css": {Data: []byte("a")}}, false)
+	c.Assert(err, IsNil)
+	second, err := newAssets(fstest.MapFS{"app.css": {Data: []byte("b")}}, false)
+	c.Assert(err, IsNil)
+
+	c.Assert(first.AssetURL("app.css"), Not(Equals), second.AssetURL("app.css"))
+}
+
+func (s *AssetsSuite) TestAssetURLInTemplate(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	assets, _ := rg.Group("/static").Assets("/", testFS)
+
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{"asset": assets.AssetURL}).Parse(`<link href="{{ asset "css/site.css" }}">`))
+	var out strings.Builder
+	c.Assert(tmpl.Execute(&out, nil), IsNil)
+	c.Assert(out.String(), Equals, `<link href="/static/`+assets.Manifest()["css/site.css"]+`">`)
+}
@@ -0,0 +1,203 @@
+package webapp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type authKey int
+
+const principalKey authKey = iota
+
+var (
+	// ErrMissingCredentials is the error used when the request has no credentials
+	ErrMissingCredentials = errors.New("missing credentials")
+
+	// ErrInvalidCredentials is the error used when the credentials are rejected by the validator
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity is the authenticated principal of the request
+type Identity struct {
+	// Subject identifies the principal, e.g. the username
+	Subject string
+
+	// Scheme is the authentication scheme used (basic, bearer, apikey)
+	Scheme string
+
+	Roles  []string
+	Scopes []string
+
+	// Attributes holds additional information provided by the validator
+	Attributes map[string]interface{}
+}
+
+// BasicValidator validates the username and password, a nil identity
+// or an error rejects the credentials
+type BasicValidator func(ctx context.Context, username, password string) (*Identity, error)
+
+// TokenValidator validates a bearer token or api key, a nil identity
+// or an error rejects the token
+type TokenValidator func(ctx context.Context, token string) (*Identity, error)
+
+// NewContextWithPrincipal stores the authenticated principal in the context
+func NewContextWithPrincipal(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, principalKey, identity)
+}
+
+// Principal retrieves the authenticated principal from the context,
+// nil is returned when the request is not authenticated
+func Principal(ctx context.Context) *Identity {
+	if ctx == nil {
+		return nil
+	}
+
+	if identity, ok := ctx.Value(principalKey).(*Identity); ok {
+		return identity
+	}
+
+	return nil
+}
+
+// BasicAuth is a middleware authenticating the request with HTTP Basic authentication.
+// Failed requests get a 401 with a Basic challenge for the realm through HandleError.
+func BasicAuth(realm string, validator BasicValidator) Middleware {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			username, password, ok := req.BasicAuth()
+			if !ok {
+				unauthorized(ctx, rw, req, challenge, ErrMissingCredentials)
+				return
+			}
+
+			identity, err := validator(ctx, username, password)
+			if err != nil || identity == nil {
+				unauthorized(ctx, rw, req, challenge, ErrInvalidCredentials)
+				return
+			}
+
+			if identity.Scheme == "" {
+				identity.Scheme = "basic"
+			}
+			next(NewContextWithPrincipal(ctx, identity), rw, req)
+		}
+	}
+}
+
+// BasicAuthUsers creates a validator for a static set of username and passwords,
+// the credentials are compared in constant time
+func BasicAuthUsers(users map[string]string) BasicValidator {
+	hashes := make(map[string][32]byte, len(users))
+	for username, password := range users {
+		hashes[username] = sha256.Sum256([]byte(password))
+	}
+
+	// compared against unknown users to keep the timing equal
+	dummy := sha256.Sum256(nil)
+
+	return func(_ context.Context, username, password string) (*Identity, error) {
+		expected, ok := hashes[username]
+		if !ok {
+			expected = dummy
+		}
+
+		given := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(given[:], expected[:]) != 1 || !ok {
+			return nil, ErrInvalidCredentials
+		}
+		return &Identity{Subject: username}, nil
+	}
+}
+
+// BearerAuth is a middleware authenticating the request with a bearer token from the Authorization header.
+// Failed requests get a 401 with a Bearer challenge for the realm through HandleError.
+func BearerAuth(realm string, validator TokenValidator) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			token, ok := bearerToken(req)
+			if !ok {
+				unauthorized(ctx, rw, req, fmt.Sprintf("Bearer realm=%q", realm), ErrMissingCredentials)
+				return
+			}
+
+			identity, err := validator(ctx, token)
+			if err != nil || identity == nil {
+				// the error of the validator is not disclosed, it may describe internal failures
+				challenge := fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\", error_description=\"token invalid\"", realm)
+				unauthorized(ctx, rw, req, challenge, ErrInvalidCredentials)
+				return
+			}
+
+			if identity.Scheme == "" {
+				identity.Scheme = "bearer"
+			}
+			next(NewContextWithPrincipal(ctx, identity), rw, req)
+		}
+	}
+}
+
+// APIKeyConfig configures the APIKeyAuth middleware
+type APIKeyConfig struct {
+	// Header is the request header holding the key, e.g. X-Api-Key
+	Header string
+
+	// QueryParam is the query parameter holding the key, checked when the header is absent
+	QueryParam string
+
+	Realm     string
+	Validator TokenValidator
+}
+
+// APIKeyAuth is a middleware authenticating the request with an api key from a header or query parameter.
+// Failed requests get a 401 through HandleError.
+func APIKeyAuth(config APIKeyConfig) Middleware {
+	challenge := fmt.Sprintf("APIKey realm=%q", config.Realm)
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			key := ""
+			if config.Header != "" {
+				key = req.Header.Get(config.Header)
+			}
+			if key == "" && config.QueryParam != "" {
+				key = req.URL.Query().Get(config.QueryParam)
+			}
+			if key == "" {
+				unauthorized(ctx, rw, req, challenge, ErrMissingCredentials)
+				return
+			}
+
+			identity, err := config.Validator(ctx, key)
+			if err != nil || identity == nil {
+				unauthorized(ctx, rw, req, challenge, ErrInvalidCredentials)
+				return
+			}
+
+			if identity.Scheme == "" {
+				identity.Scheme = "apikey"
+			}
+			next(NewContextWithPrincipal(ctx, identity), rw, req)
+		}
+	}
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	auth := req.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+func unauthorized(ctx context.Context, rw http.ResponseWriter, req *http.Request, challenge string, err error) {
+	rw.Header().Set("WWW-Authenticate", challenge)
+	HandleError(ctx, rw, req, NewHTTPError(http.StatusUnauthorized, err))
+}
@@ -0,0 +1,111 @@
+package webapp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+)
+
+type AuthSuite struct{}
+
+var _ = Suite(&AuthSuite{})
+
+func principalHandler(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	identity := Principal(ctx)
+	rw.Write([]byte(identity.Scheme + ":" + identity.Subject))
+}
+
+func (s *AuthSuite) TestBasicAuth(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(BasicAuth("admin", BasicAuthUsers(map[string]string{"john": "secret"}))).GET("/", principalHandler)
+
+	tests := []struct {
+		username, password string
+		code               int
+		body               string
+	}{
+		{username: "john", password: "secret", code: 200, body: "basic:john"},
+		{username: "john", password: "wrong", code: 401},
+		{username: "jane", password: "secret", code: 401},
+	}
+
+	for index, test := range tests {
+		credentials := base64.StdEncoding.EncodeToString([]byte(test.username + ":" + test.password))
+		response := doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Basic " + credentials})
+
+		c.Check(response.Code, Equals, test.code, Commentf("test %d failed", index))
+		if test.code == 200 {
+			c.Check(response.Body.String(), Equals, test.body, Commentf("test %d failed", index))
+		} else {
+			c.Check(response.Header().Get("WWW-Authenticate"), Equals, `Basic realm="admin", charset="UTF-8"`, Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *AuthSuite) TestBasicAuthMissingCredentials(c *C) {
+	var err error
+	rg := newRouteGroup(httprouter.New())
+	rg.Use(ErrorHandler(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		err = Error(ctx)
+		rw.WriteHeader(ErrorStatus(err))
+	}))
+	rg.With(BasicAuth("admin", BasicAuthUsers(nil))).GET("/", principalHandler)
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 401)
+	c.Assert(errors.Is(err, ErrMissingCredentials), Equals, true)
+}
+
+func tokenValidator(_ context.Context, token string) (*Identity, error) {
+	if token == "valid" {
+		return &Identity{Subject: "service", Scopes: []string{"read"}}, nil
+	}
+	return nil, errors.New("token expired")
+}
+
+func (s *AuthSuite) TestBearerAuth(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(BearerAuth("api", tokenValidator)).GET("/", principalHandler)
+
+	response := doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Bearer valid"})
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "bearer:service")
+
+	response = doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Bearer invalid"})
+	c.Assert(response.Code, Equals, 401)
+	c.Assert(response.Header().Get("WWW-Authenticate"), Equals, `Bearer realm="api", error="invalid_token", error_description="token invalid"`)
+
+	response = doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Basic abc"})
+	c.Assert(response.Code, Equals, 401)
+	c.Assert(response.Header().Get("WWW-Authenticate"), Equals, `Bearer realm="api"`)
+}
+
+func (s *AuthSuite) TestAPIKeyAuth(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(APIKeyAuth(APIKeyConfig{
+		Header:     "X-Api-Key",
+		QueryParam: "api_key",
+		Realm:      "api",
+		Validator:  tokenValidator,
+	})).GET("/", principalHandler)
+
+	response := doTestRequest(rg, "GET", "/", map[string]string{"X-Api-Key": "valid"})
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "apikey:service")
+
+	response = doTestRequest(rg, "GET", "/?api_key=valid")
+	c.Assert(response.Code, Equals, 200)
+
+	response = doTestRequest(rg, "GET", "/?api_key=invalid")
+	c.Assert(response.Code, Equals, 401)
+	c.Assert(response.Header().Get("WWW-Authenticate"), Equals, `APIKey realm="api"`)
+}
+
+func (s *AuthSuite) TestPrincipalWithoutAuthentication(c *C) {
+	c.Assert(Principal(nil), IsNil)
+	c.Assert(Principal(context.Background()), IsNil)
+}
@@ -0,0 +1,116 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrForbidden is the error used when the principal does not meet the requirements of the route
+var ErrForbidden = errors.New("forbidden")
+
+// Policy decides if the request is allowed, returning an error denies the request.
+// The authenticated principal is available using Principal(ctx).
+type Policy func(ctx context.Context, req *http.Request) error
+
+// Guard only allows the requests meeting its requirement. Guards are added to the routes
+// of a group with Require, which records the requirements in the route introspection.
+// The Middleware of a guard can also be used on its own, without being recorded.
+type Guard struct {
+	// Requirement describes the guard in the route introspection, e.g. roles:admin
+	Requirement string
+
+	policy Policy
+}
+
+// RequireScopes is a guard that only allows principals having all the scopes.
+// Unauthenticated requests get a 401, principals missing a scope get a 403 through HandleError.
+//
+//     api := app.Group("/api", webapp.JWT(config))
+//     api.Require(webapp.RequireScopes("orders:read")).GET("/orders", listOrders)
+func RequireScopes(scopes ...string) Guard {
+	requirement := "scopes:" + strings.Join(scopes, ",")
+	return guard(requirement, func(ctx context.Context, _ *http.Request) error {
+		granted := Principal(ctx).Scopes
+		for _, scope := range scopes {
+			if !containsString(granted, scope) {
+				return fmt.Errorf("%w: missing scope %s", ErrForbidden, scope)
+			}
+		}
+		return nil
+	})
+}
+
+// RequireRoles is a guard that only allows principals having at least one of the roles.
+// Unauthenticated requests get a 401, principals without any of the roles get a 403 through HandleError.
+func RequireRoles(roles ...string) Guard {
+	requirement := "roles:" + strings.Join(roles, "|")
+	return guard(requirement, func(ctx context.Context, _ *http.Request) error {
+		granted := Principal(ctx).Roles
+		for _, role := range roles {
+			if containsString(granted, role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: requires one of the roles %s", ErrForbidden, strings.Join(roles, ", "))
+	})
+}
+
+// RequirePolicy is a guard that only allows requests accepted by the policy,
+// the name is recorded in the route introspection.
+// Unauthenticated requests get a 401, denied requests get a 403 through HandleError.
+func RequirePolicy(name string, policy Policy) Guard {
+	return guard("policy:"+name, func(ctx context.Context, req *http.Request) error {
+		if err := policy(ctx, req); err != nil {
+			return fmt.Errorf("%w: %v", ErrForbidden, err)
+		}
+		return nil
+	})
+}
+
+func guard(requirement string, policy Policy) Guard {
+	return Guard{Requirement: requirement, policy: policy}
+}
+
+// Middleware checks the principal of the request against the guard
+func (guard Guard) Middleware(next ContextHandler) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		if Principal(ctx) == nil {
+			HandleError(ctx, rw, req, NewHTTPError(http.StatusUnauthorized, ErrMissingCredentials))
+			return
+		}
+
+		if err := guard.policy(ctx, req); err != nil {
+			HandleError(ctx, rw, req, NewHTTPError(http.StatusForbidden, err))
+			return
+		}
+
+		next(ctx, rw, req)
+	}
+}
+
+// Require creates a new Group with the same path guarding its routes with the guards,
+// the requirements of the guards are recorded on the routes
+func (group *routeGroup) Require(guards ...Guard) RouteGroup {
+	middleware := make([]Middleware, len(guards))
+	requirements := group.requirements[:len(group.requirements):len(group.requirements)]
+	for i, guard := range guards {
+		middleware[i] = guard.Middleware
+		requirements = append(requirements, guard.Requirement)
+	}
+
+	required := group.With(middleware...).(*routeGroup)
+	required.requirements = requirements
+	return required
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+)
+
+type AuthzSuite struct{}
+
+var _ = Suite(&AuthzSuite{})
+
+func authenticateAs(identity *Identity) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			if identity != nil {
+				ctx = NewContextWithPrincipal(ctx, identity)
+			}
+			next(ctx, rw, req)
+		}
+	}
+}
+
+func (s *AuthzSuite) TestGuards(c *C) {
+	reader := &Identity{Subject: "reader", Scopes: []string{"read"}, Roles: []string{"user"}}
+	editor := &Identity{Subject: "editor", Scopes: []string{"read", "write"}, Roles: []string{"editor"}}
+
+	tests := []struct {
+		identity *Identity
+		guard    Guard
+		code     int
+	}{
+		{identity: nil, guard: RequireScopes("read"), code: 401},
+		{identity: reader, guard: RequireScopes("read"), code: 200},
+		{identity: reader, guard: RequireScopes("read", "write"), code: 403},
+		{identity: editor, guard: RequireScopes("read", "write"), code: 200},
+		{identity: reader, guard: RequireRoles("admin", "editor"), code: 403},
+		{identity: editor, guard: RequireRoles("admin", "editor"), code: 200},
+	}
+
+	for index, test := range tests {
+		rg := newRouteGroup(httprouter.New())
+		rg.With(authenticateAs(test.identity)).Require(test.guard).GET("/", finalHandler)
+
+		response := doTestRequest(rg, "GET", "/")
+
+		c.Check(response.Code, Equals, test.code, Commentf("test %d failed", index))
+	}
+}
+
+func (s *AuthzSuite) TestPolicyUsesCentralErrorPath(c *C) {
+	var err error
+	owner := RequirePolicy("owner", func(ctx context.Context, req *http.Request) error {
+		if Principal(ctx).Subject != Param(ctx, "user") {
+			return errors.New("not the owner")
+		}
+		return nil
+	})
+
+	rg := newRouteGroup(httprouter.New())
+	rg.Use(ErrorHandler(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		err = Error(ctx)
+		rw.WriteHeader(ErrorStatus(err))
+	}))
+	rg.Group("/users", authenticateAs(&Identity{Subject: "john"})).Require(owner).GET("/:user", finalHandler)
+
+	c.Assert(doTestRequest(rg, "GET", "/users/john").Code, Equals, 200)
+
+	response := doTestRequest(rg, "GET", "/users/jane")
+	c.Assert(response.Code, Equals, 403)
+	c.Assert(errors.Is(err, ErrForbidden), Equals, true)
+	c.Assert(err, ErrorMatches, "forbidden: not the owner")
+}
+
+func (s *AuthzSuite) TestGuardsAreRecordedOnRoutes(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	admin := rg.Group("/admin").Require(RequireRoles("admin"))
+	admin.GET("/users", finalHandler)
+	admin.Require(RequireScopes("users:write"), RequirePolicy("owner", func(context.Context, *http.Request) error { return nil })).DELETE("/users/:id", finalHandler)
+	admin.Group("/audit", middlewareWriter("audit")).GET("/", finalHandler)
+	rg.GET("/public", finalHandler)
+
+	routes := rg.Routes()
+
+	c.Assert(routes, HasLen, 4)
+	c.Assert(routes[0].Requirements, DeepEquals, []string{"roles:admin"})
+	c.Assert(routes[0].Middlewares, Equals, 1)
+	c.Assert(routes[1].Requirements, DeepEquals, []string{"roles:admin", "scopes:users:write", "policy:owner"})
+	c.Assert(routes[2].Requirements, DeepEquals, []string{"roles:admin"})
+	c.Assert(routes[3].Requirements, HasLen, 0)
+}
+
+func (s *AuthzSuite) TestGuardMiddlewareIsNotRecorded(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(RequireRoles("admin").Middleware).GET("/", finalHandler)
+
+	c.Assert(doTestRequest(rg, "GET", "/").Code, Equals, 401)
+	c.Assert(rg.Routes()[0].Requirements, HasLen, 0)
+}
@@ -0,0 +1,235 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// BufferedResponseWriter is the ResponseWriter passed on by the Buffer middleware.
+// Middleware after Buffer can inspect and change the response after calling next,
+// nothing is sent to the client until the Buffer middleware returns.
+//
+//     func Uppercase(next webapp.ContextHandler) webapp.ContextHandler {
+//         return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+//             next(ctx, rw, req)
+//             if buffered, ok := rw.(webapp.BufferedResponseWriter); ok && buffered.Buffered() {
+//                 buffered.SetBody(bytes.ToUpper(buffered.Body()))
+//             }
+//         }
+//     }
+type BufferedResponseWriter interface {
+	ResponseWriter
+
+	// Buffered reports if the response is held in memory, false when the size cap
+	// is exceeded or the response is flushed and the response is streamed to the client
+	Buffered() bool
+
+	// Body returns the buffered body
+	Body() []byte
+
+	// SetBody replaces the buffered body
+	SetBody(body []byte)
+
+	// Reset discards the buffered status, body and headers set after the
+	// Buffer middleware, use it to replace the response
+	Reset()
+}
+
+// Buffer is a middleware that holds the response in memory until the handler returns.
+// When the body grows beyond maxSize the buffered data is sent and the remaining
+// response is streamed, a maxSize of 0 buffers the complete response.
+// Flushing the response also switches to streaming.
+func Buffer(maxSize int) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			buffered := newBufferedResponseWriter(rw, maxSize)
+			next(ctx, buffered, req)
+			buffered.flush()
+		}
+	}
+}
+
+type bufferedResponseWriter struct {
+	rw          ResponseWriter
+	snapshot    http.Header
+	maxSize     int
+	status      int
+	body        bytes.Buffer
+	bodyWritten bool
+	streaming   bool
+}
+
+func newBufferedResponseWriter(rw http.ResponseWriter, maxSize int) *bufferedResponseWriter {
+	responseWriter, ok := rw.(ResponseWriter)
+	if !ok {
+		responseWriter = newResponseWriter(rw)
+	}
+
+	return &bufferedResponseWriter{
+		rw:       responseWriter,
+		snapshot: responseWriter.Header().Clone(),
+		maxSize:  maxSize,
+	}
+}
+
+func (rw *bufferedResponseWriter) Header() http.Header {
+	return rw.rw.Header()
+}
+
+func (rw *bufferedResponseWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		rw.rw.WriteHeader(status)
+		return
+	}
+
+	if rw.status != 0 {
+		return
+	}
+
+	rw.status = status
+	if rw.streaming {
+		rw.rw.WriteHeader(status)
+	}
+}
+
+func (rw *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.bodyWritten = true
+
+	if !rw.streaming && rw.maxSize > 0 && rw.body.Len()+len(data) > rw.maxSize {
+		if err := rw.stream(); err != nil {
+			return 0, err
+		}
+	}
+
+	if rw.streaming {
+		return rw.rw.Write(data)
+	}
+	return rw.body.Write(data)
+}
+
+func (rw *bufferedResponseWriter) Flush() {
+	rw.stream()
+	rw.rw.Flush()
+}
+
+func (rw *bufferedResponseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *bufferedResponseWriter) Written() bool {
+	return rw.status != 0
+}
+
+func (rw *bufferedResponseWriter) HeaderWritten() bool {
+	return rw.status != 0
+}
+
+func (rw *bufferedResponseWriter) BodyWritten() bool {
+	return rw.bodyWritten
+}
+
+func (rw *bufferedResponseWriter) Size() int {
+	if rw.streaming {
+		return rw.rw.Size()
+	}
+	return rw.body.Len()
+}
+
+// Before registers the function on the underlying writer, it is called
+// when the buffered response is sent
+func (rw *bufferedResponseWriter) Before(before func(ResponseWriter)) {
+	rw.rw.Before(before)
+}
+
+// Unwrap returns the underlying writer, used by http.ResponseController
+func (rw *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.rw
+}
+
+func (rw *bufferedResponseWriter) Buffered() bool {
+	return !rw.streaming
+}
+
+func (rw *bufferedResponseWriter) Body() []byte {
+	if rw.streaming {
+		return nil
+	}
+	return rw.body.Bytes()
+}
+
+func (rw *bufferedResponseWriter) SetBody(body []byte) {
+	if rw.streaming {
+		return
+	}
+	rw.body.Reset()
+	rw.body.Write(body)
+	rw.bodyWritten = true
+	rw.Header().Del("Content-Length")
+}
+
+func (rw *bufferedResponseWriter) Reset() {
+	if rw.streaming {
+		return
+	}
+
+	rw.status = 0
+	rw.bodyWritten = false
+	rw.body.Reset()
+
+	header := rw.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range rw.snapshot {
+		header[key] = append([]string(nil), values...)
+	}
+}
+
+// stream sends the buffered status and body and passes all following writes to the client
+func (rw *bufferedResponseWriter) stream() error {
+	if rw.streaming {
+		return nil
+	}
+	rw.streaming = true
+
+	if rw.status == 0 {
+		return nil
+	}
+	rw.rw.WriteHeader(rw.status)
+
+	if rw.body.Len() > 0 {
+		_, err := rw.rw.Write(rw.body.Bytes())
+		rw.body.Reset()
+		return err
+	}
+	return nil
+}
+
+// flush sends the buffered response when the handler is done
+func (rw *bufferedResponseWriter) flush() {
+	if rw.streaming || rw.status == 0 {
+		return
+	}
+
+	if bodyAllowedForStatus(rw.status) && rw.Header().Get("Content-Length") == "" {
+		rw.Header().Set("Content-Length", strconv.Itoa(rw.body.Len()))
+	}
+	rw.stream()
+}
+
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+)
+
+type BufferSuite struct{}
+
+var _ = Suite(&BufferSuite{})
+
+// middlewareInspect runs after the handler and passes the buffered writer to fn
+func middlewareInspect(fn func(BufferedResponseWriter)) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			next(ctx, rw, req)
+			fn(rw.(BufferedResponseWriter))
+		}
+	}
+}
+
+func (s *BufferSuite) TestBufferRewritesBody(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Buffer(0), middlewareInspect(func(rw BufferedResponseWriter) {
+		c.Check(rw.Buffered(), Equals, true)
+		c.Check(rw.Status(), Equals, 200)
+		rw.SetBody(bytes.ToLower(rw.Body()))
+	})).GET("/", finalHandler)
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "h")
+	c.Assert(response.Header().Get("Content-Length"), Equals, "1")
+}
+
+func (s *BufferSuite) TestBufferNothingIsSentBeforeHandlerReturns(c *C) {
+	rec := httptest.NewRecorder()
+	handler := Buffer(0)(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("body"))
+
+		c.Check(rec.Body.Len(), Equals, 0)
+		c.Check(rw.(ResponseWriter).Size(), Equals, 4)
+	})
+
+	handler(context.Background(), rec, nil)
+
+	c.Assert(rec.Code, Equals, 201)
+	c.Assert(rec.Body.String(), Equals, "body")
+}
+
+func (s *BufferSuite) TestBufferReplaceResponseOnError(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Buffer(0), middlewareInspect(func(rw BufferedResponseWriter) {
+		rw.Reset()
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte("replaced"))
+	})).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Partial", "yes")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("partial"))
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 500)
+	c.Assert(response.Body.String(), Equals, "replaced")
+	c.Assert(response.Header().Get("X-Partial"), Equals, "")
+}
+
+func (s *BufferSuite) TestBufferFallsBackToStreaming(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Buffer(4), middlewareInspect(func(rw BufferedResponseWriter) {
+		c.Check(rw.Buffered(), Equals, false)
+		c.Check(rw.Body(), IsNil)
+		c.Check(rw.Size(), Equals, 6)
+	})).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("123"))
+		rw.Write([]byte("456"))
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "123456")
+}
+
+func (s *BufferSuite) TestBufferFlushStreams(c *C) {
+	rec := httptest.NewRecorder()
+	handler := Buffer(0)(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("event"))
+		rw.(http.Flusher).Flush()
+
+		c.Check(rec.Body.String(), Equals, "event")
+		c.Check(rec.Flushed, Equals, true)
+	})
+
+	handler(context.Background(), rec, nil)
+}
+
+func (s *BufferSuite) TestBufferBeforeHooksRunWhenSent(c *C) {
+	rec := httptest.NewRecorder()
+	called := false
+	handler := Buffer(0)(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.(ResponseWriter).Before(func(ResponseWriter) {
+			called = true
+		})
+		rw.Write([]byte("body"))
+
+		c.Check(called, Equals, false)
+	})
+
+	handler(context.Background(), rec, nil)
+
+	c.Assert(called, Equals, true)
+}
+
+func (s *BufferSuite) TestBufferEmptyResponse(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Buffer(0)).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 204)
+	c.Assert(response.Header().Get("Content-Length"), Equals, "")
+}
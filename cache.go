@@ -0,0 +1,474 @@
+package webapp
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response stored by the Cache middleware
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// Vary holds the request headers the response varies on
+	Vary []string
+
+	Created time.Time
+
+	// Expires is the end of the fresh period, until StaleUntil the response
+	// is served stale while it is revalidated in the background
+	Expires    time.Time
+	StaleUntil time.Time
+}
+
+// CacheStore stores the cached responses.
+// Get must not return responses after their StaleUntil time.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, response *CachedResponse)
+	Delete(key string)
+}
+
+// CacheConfig configures the Cache middleware
+type CacheConfig struct {
+	// TTL is the fresh period of responses without a max-age or s-maxage directive
+	TTL time.Duration
+
+	// StaleWhileRevalidate is used for responses without a stale-while-revalidate directive
+	StaleWhileRevalidate time.Duration
+
+	// MaxBodySize is the largest body stored, larger responses are streamed and not cached
+	MaxBodySize int
+
+	Store CacheStore
+}
+
+// DefaultCacheConfig returns a configuration caching responses for a minute
+// in a memory store holding 1024 responses
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		TTL:         time.Minute,
+		MaxBodySize: 1 << 20,
+		Store:       NewMemoryCacheStore(1024),
+	}
+}
+
+// Cache is a middleware that caches GET and HEAD responses keyed by method, path, query
+// and the request headers named in the Vary response header.
+// The Cache-Control directives no-store, no-cache and private prevent caching a response,
+// max-age, s-maxage and stale-while-revalidate override the configured periods.
+// Requests with no-cache, no-store or max-age=0 skip the cache, requests with an
+// Authorization header are never cached.
+//
+// Concurrent misses on the same key are coalesced, only one runs the handler and the others
+// wait for its response. Stale responses are served while a single request revalidates them.
+//
+//     app.Group("/catalog", webapp.Cache(webapp.DefaultCacheConfig()))
+func Cache(config CacheConfig) Middleware {
+	if config.TTL < 0 || config.StaleWhileRevalidate < 0 {
+		panic("cache durations cannot be negative")
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryCacheStore(0)
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		cache := &responseCache{
+			config: config,
+			next:   next,
+			calls:  make(map[string]*cacheCall),
+		}
+		return cache.serve
+	}
+}
+
+type responseCache struct {
+	config CacheConfig
+	next   ContextHandler
+
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+// cacheCall is a handler run shared by requests for the same key
+type cacheCall struct {
+	done     chan struct{}
+	response *CachedResponse
+	variant  string
+}
+
+func (cache *responseCache) serve(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	if (req.Method != "GET" && req.Method != "HEAD") || req.Header.Get("Authorization") != "" {
+		cache.next(ctx, rw, req)
+		return
+	}
+
+	directives := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		cache.next(ctx, rw, req)
+		return
+	}
+
+	key := cacheKey(req)
+	_, noCache := directives["no-cache"]
+	if !noCache && directives["max-age"] != "0" {
+		if cached, ok := cache.lookup(key, req); ok {
+			now := time.Now()
+			if now.Before(cached.Expires) {
+				writeCachedResponse(rw, req, cached, now)
+				return
+			}
+			if now.Before(cached.StaleUntil) {
+				writeCachedResponse(rw, req, cached, now)
+				cache.revalidate(ctx, req, key)
+				return
+			}
+		}
+	}
+
+	call, leader := cache.join(key)
+	if leader {
+		var response *CachedResponse
+		defer func() {
+			cache.finish(key, call, req, response)
+		}()
+		response = cache.fetch(ctx, rw, req, key)
+		return
+	}
+
+	// the client may give up while waiting for the response of another request
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		return
+	}
+
+	if call.response != nil && call.variant == variantKey(key, call.response.Vary, req) {
+		writeCachedResponse(rw, req, call.response, time.Now())
+		return
+	}
+	cache.fetch(ctx, rw, req, key)
+}
+
+func (cache *responseCache) lookup(key string, req *http.Request) (*CachedResponse, bool) {
+	cached, ok := cache.config.Store.Get(key)
+	if ok && len(cached.Vary) > 0 && cached.Status == 0 {
+		cached, ok = cache.config.Store.Get(variantKey(key, cached.Vary, req))
+	}
+	return cached, ok
+}
+
+// join returns the running call for key, leader is true when the caller has to run it
+func (cache *responseCache) join(key string) (call *cacheCall, leader bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if call, ok := cache.calls[key]; ok {
+		return call, false
+	}
+
+	call = &cacheCall{done: make(chan struct{})}
+	cache.calls[key] = call
+	return call, true
+}
+
+func (cache *responseCache) finish(key string, call *cacheCall, req *http.Request, response *CachedResponse) {
+	cache.mu.Lock()
+	delete(cache.calls, key)
+	cache.mu.Unlock()
+
+	if response != nil {
+		call.response = response
+		call.variant = variantKey(key, response.Vary, req)
+	}
+	close(call.done)
+}
+
+// revalidate runs the handler in the background to refresh a stale response
+func (cache *responseCache) revalidate(ctx context.Context, req *http.Request, key string) {
+	call, leader := cache.join(key)
+	if !leader {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	req = req.Clone(ctx)
+	go func() {
+		var response *CachedResponse
+		defer func() {
+			cache.finish(key, call, req, response)
+		}()
+		response = cache.fetch(ctx, &discardResponseWriter{header: make(http.Header)}, req, key)
+	}()
+}
+
+// fetch runs the handler and stores the response when it is cacheable
+func (cache *responseCache) fetch(ctx context.Context, rw http.ResponseWriter, req *http.Request, key string) *CachedResponse {
+	buffered := newBufferedResponseWriter(rw, cache.config.MaxBodySize)
+	cache.next(ctx, buffered, req)
+
+	response := cache.capture(buffered, time.Now())
+	buffered.flush()
+	if response == nil {
+		return nil
+	}
+
+	if len(response.Vary) > 0 {
+		cache.config.Store.Set(key, &CachedResponse{
+			Vary:       response.Vary,
+			Created:    response.Created,
+			Expires:    response.Expires,
+			StaleUntil: response.StaleUntil,
+		})
+		cache.config.Store.Set(variantKey(key, response.Vary, req), response)
+	} else {
+		cache.config.Store.Set(key, response)
+	}
+	return response
+}
+
+// capture returns the buffered response or nil when it may not be cached
+func (cache *responseCache) capture(rw *bufferedResponseWriter, now time.Time) *CachedResponse {
+	if !rw.Buffered() || !cacheableStatus(rw.Status()) {
+		return nil
+	}
+
+	header := rw.Header()
+	if header.Get("Set-Cookie") != "" {
+		return nil
+	}
+
+	var vary []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				vary = append(vary, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return nil
+		}
+	}
+
+	ttl := cache.config.TTL
+	if seconds, ok := directiveSeconds(directives, "s-maxage"); ok {
+		ttl = seconds
+	} else if seconds, ok := directiveSeconds(directives, "max-age"); ok {
+		ttl = seconds
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	stale := cache.config.StaleWhileRevalidate
+	if seconds, ok := directiveSeconds(directives, "stale-while-revalidate"); ok {
+		stale = seconds
+	}
+
+	// only keep the headers set after the cache, the headers of the
+	// middleware before it are set again on every request
+	stored := make(http.Header)
+	for key, values := range header {
+		if key == "Content-Length" || key == "Age" || equalValues(rw.snapshot[key], values) {
+			continue
+		}
+		stored[key] = append([]string(nil), values...)
+	}
+
+	return &CachedResponse{
+		Status:     rw.Status(),
+		Header:     stored,
+		Body:       append([]byte(nil), rw.Body()...),
+		Vary:       vary,
+		Created:    now,
+		Expires:    now.Add(ttl),
+		StaleUntil: now.Add(ttl + stale),
+	}
+}
+
+func writeCachedResponse(rw http.ResponseWriter, req *http.Request, cached *CachedResponse, now time.Time) {
+	header := rw.Header()
+	for key, values := range cached.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(cached.Created).Seconds())))
+	if bodyAllowedForStatus(cached.Status) {
+		header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+	}
+
+	rw.WriteHeader(cached.Status)
+	if req.Method != "HEAD" {
+		rw.Write(cached.Body)
+	}
+}
+
+// cacheKey identifies the response by method, host, path and the sorted query,
+// the host is part of the key as routes can differ per host
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + requestHost(req) + req.URL.Path + "?" + req.URL.Query().Encode()
+}
+
+func variantKey(key string, vary []string, req *http.Request) string {
+	for _, name := range vary {
+		key += "\n" + name + ":" + strings.Join(req.Header.Values(name), ",")
+	}
+	return key
+}
+
+// cacheableStatus reports if the status is cacheable by default, see RFC 7231 section 6.1
+func cacheableStatus(status int) bool {
+	switch status {
+	case 200, 203, 204, 300, 301, 404, 405, 410, 414, 501:
+		return true
+	}
+	return false
+}
+
+// parseCacheControl returns the directives of a Cache-Control header, directives
+// without a value are present with an empty value
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, argument, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(argument), `"`)
+	}
+	return directives
+}
+
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// discardResponseWriter is the writer for background revalidations
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (rw *discardResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *discardResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (rw *discardResponseWriter) WriteHeader(int) {}
+
+const defaultCacheEntries = 1024
+
+type memoryCacheStore struct {
+	sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type memoryCacheEntry struct {
+	key      string
+	response *CachedResponse
+}
+
+// NewMemoryCacheStore creates an in memory store holding up to maxEntries responses,
+// the least recently used response is evicted when the store is full.
+// When maxEntries is 0 a default of 1024 entries is used.
+func NewMemoryCacheStore(maxEntries int) CacheStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheEntries
+	}
+
+	return &memoryCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (store *memoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	store.Lock()
+	defer store.Unlock()
+
+	element, ok := store.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryCacheEntry)
+	if !time.Now().Before(entry.response.StaleUntil) {
+		store.remove(element)
+		return nil, false
+	}
+
+	store.lru.MoveToFront(element)
+	return entry.response, true
+}
+
+func (store *memoryCacheStore) Set(key string, response *CachedResponse) {
+	store.Lock()
+	defer store.Unlock()
+
+	if element, ok := store.entries[key]; ok {
+		element.Value.(*memoryCacheEntry).response = response
+		store.lru.MoveToFront(element)
+		return
+	}
+
+	store.entries[key] = store.lru.PushFront(&memoryCacheEntry{key: key, response: response})
+	for store.lru.Len() > store.maxEntries {
+		store.remove(store.lru.Back())
+	}
+}
+
+func (store *memoryCacheStore) Delete(key string) {
+	store.Lock()
+	defer store.Unlock()
+
+	if element, ok := store.entries[key]; ok {
+		store.remove(element)
+	}
+}
+
+func (store *memoryCacheStore) remove(element *list.Element) {
+	store.lru.Remove(element)
+	delete(store.entries, element.Value.(*memoryCacheEntry).key)
+}
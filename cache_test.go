@@ -0,0 +1,242 @@
+package webapp
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CacheSuite struct{}
+
+var _ = Suite(&CacheSuite{})
+
+// countingHandler writes the number of calls as body after setting the given headers
+func countingHandler(calls *int32, headers map[string]string) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		count := atomic.AddInt32(calls, 1)
+		for key, value := range headers {
+			rw.Header().Set(key, value)
+		}
+		fmt.Fprintf(rw, "%d", count)
+	}
+}
+
+func (s *CacheSuite) TestCacheHit(c *C) {
+	var calls int32
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(DefaultCacheConfig())).GET("/", countingHandler(&calls, map[string]string{"Content-Type": "text/plain"}))
+
+	c.Assert(doTestRequest(rg, "GET", "/?a=1&b=2").Body.String(), Equals, "1")
+
+	response := doTestRequest(rg, "GET", "/?b=2&a=1")
+	c.Assert(response.Body.String(), Equals, "1")
+	c.Assert(response.Header().Get("Content-Type"), Equals, "text/plain")
+	c.Assert(response.Header().Get("Age"), Equals, "0")
+	c.Assert(response.Header().Get("Content-Length"), Equals, "1")
+
+	c.Assert(doTestRequest(rg, "GET", "/?a=2").Body.String(), Equals, "2")
+}
+
+func (s *CacheSuite) TestCacheRequestDirectives(c *C) {
+	var calls int32
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(DefaultCacheConfig())).GET("/", countingHandler(&calls, nil))
+
+	doTestRequest(rg, "GET", "/")
+
+	c.Assert(doTestRequest(rg, "GET", "/", map[string]string{"Cache-Control": "no-cache"}).Body.String(), Equals, "2")
+	c.Assert(doTestRequest(rg, "GET", "/").Body.String(), Equals, "2")
+	c.Assert(doTestRequest(rg, "GET", "/", map[string]string{"Cache-Control": "no-store"}).Body.String(), Equals, "3")
+	c.Assert(doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Bearer token"}).Body.String(), Equals, "4")
+	c.Assert(doTestRequest(rg, "GET", "/").Body.String(), Equals, "2")
+}
+
+func (s *CacheSuite) TestCacheResponseDirectives(c *C) {
+	for _, headers := range []map[string]string{
+		{"Cache-Control": "no-store"},
+		{"Cache-Control": "private, max-age=60"},
+		{"Cache-Control": "max-age=0"},
+		{"Set-Cookie": "a=b"},
+		{"Vary": "*"},
+	} {
+		var calls int32
+		rg := newRouteGroup(httprouter.New())
+		rg.With(Cache(DefaultCacheConfig())).GET("/", countingHandler(&calls, headers))
+
+		doTestRequest(rg, "GET", "/")
+		c.Check(doTestRequest(rg, "GET", "/").Body.String(), Equals, "2", Commentf("%v", headers))
+	}
+}
+
+func (s *CacheSuite) TestCacheDoesNotReplayOuterHeaders(c *C) {
+	var calls int32
+	rg := newRouteGroup(httprouter.New())
+	rg.With(func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Request", req.URL.Query().Get("id"))
+			next(ctx, rw, req)
+		}
+	}, Cache(DefaultCacheConfig())).GET("/", countingHandler(&calls, nil))
+
+	doTestRequest(rg, "GET", "/")
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Body.String(), Equals, "1")
+	c.Assert(response.Header().Get("X-Request"), Equals, "")
+}
+
+func (s *CacheSuite) TestCacheVary(c *C) {
+	var calls int32
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(DefaultCacheConfig())).GET("/", countingHandler(&calls, map[string]string{"Vary": "accept-language"}))
+
+	english := map[string]string{"Accept-Language": "en"}
+	dutch := map[string]string{"Accept-Language": "nl"}
+
+	c.Assert(doTestRequest(rg, "GET", "/", english).Body.String(), Equals, "1")
+	c.Assert(doTestRequest(rg, "GET", "/", dutch).Body.String(), Equals, "2")
+	c.Assert(doTestRequest(rg, "GET", "/", english).Body.String(), Equals, "1")
+	c.Assert(doTestRequest(rg, "GET", "/", dutch).Body.String(), Equals, "2")
+}
+
+func (s *CacheSuite) TestCacheTTL(c *C) {
+	var calls int32
+	config := DefaultCacheConfig()
+	config.TTL = 10 * time.Millisecond
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(config)).GET("/", countingHandler(&calls, nil))
+
+	doTestRequest(rg, "GET", "/")
+	c.Assert(doTestRequest(rg, "GET", "/").Body.String(), Equals, "1")
+
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(doTestRequest(rg, "GET", "/").Body.String(), Equals, "2")
+}
+
+func (s *CacheSuite) TestCacheStaleWhileRevalidate(c *C) {
+	var calls int32
+	config := DefaultCacheConfig()
+	config.TTL = 10 * time.Millisecond
+	config.StaleWhileRevalidate = time.Minute
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(config)).GET("/", countingHandler(&calls, nil))
+
+	doTestRequest(rg, "GET", "/")
+	time.Sleep(20 * time.Millisecond)
+
+	c.Assert(doTestRequest(rg, "GET", "/").Body.String(), Equals, "1")
+
+	for i := 0; i < 100 && doTestRequest(rg, "GET", "/").Body.String() != "2"; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(doTestRequest(rg, "GET", "/").Body.String(), Equals, "2")
+	c.Assert(atomic.LoadInt32(&calls), Equals, int32(2))
+}
+
+func (s *CacheSuite) TestCacheCoalescesMisses(c *C) {
+	var calls int32
+	release := make(chan struct{})
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(DefaultCacheConfig())).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		rw.Write([]byte("shared"))
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = doTestRequest(rg, "GET", "/").Body.String()
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	c.Assert(atomic.LoadInt32(&calls), Equals, int32(1))
+	for _, body := range bodies {
+		c.Check(body, Equals, "shared")
+	}
+}
+
+func (s *CacheSuite) TestCacheWaiterGivesUp(c *C) {
+	release := make(chan struct{})
+	defer close(release)
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Cache(DefaultCacheConfig())).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		<-release
+	})
+
+	go doTestRequest(rg, "GET", "/")
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/", nil)
+	done := make(chan struct{})
+	go func() {
+		rg.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.Fatal("waiting request did not give up")
+	}
+}
+
+func (s *CacheSuite) TestMemoryCacheStoreEvictsLeastRecentlyUsed(c *C) {
+	store := NewMemoryCacheStore(2)
+	response := &CachedResponse{StaleUntil: time.Now().Add(time.Minute)}
+
+	store.Set("a", response)
+	store.Set("b", response)
+	store.Get("a")
+	store.Set("c", response)
+
+	_, ok := store.Get("a")
+	c.Assert(ok, Equals, true)
+	_, ok = store.Get("b")
+	c.Assert(ok, Equals, false)
+	_, ok = store.Get("c")
+	c.Assert(ok, Equals, true)
+
+	store.Delete("c")
+	_, ok = store.Get("c")
+	c.Assert(ok, Equals, false)
+
+	store.Set("expired", &CachedResponse{StaleUntil: time.Now().Add(-time.Second)})
+	_, ok = store.Get("expired")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *CacheSuite) TestParseCacheControl(c *C) {
+	c.Assert(parseCacheControl(`public, Max-Age=60, no-cache="Set-Cookie"`), DeepEquals, map[string]string{
+		"public":   "",
+		"max-age":  "60",
+		"no-cache": "Set-Cookie",
+	})
+}
+
+func (s *CacheSuite) TestCacheKeyIncludesHost(c *C) {
+	app := New()
+	app.Use(Cache(DefaultCacheConfig()))
+	app.Host("{tenant}.example.com").GET("/me", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("tenant " + Param(ctx, "tenant")))
+	})
+
+	c.Assert(doTestRequest(app, "GET", "/me", map[string]string{"Host": "a.example.com"}).Body.String(), Equals, "tenant a")
+	c.Assert(doTestRequest(app, "GET", "/me", map[string]string{"Host": "b.example.com"}).Body.String(), Equals, "tenant b")
+	c.Assert(doTestRequest(app, "GET", "/me", map[string]string{"Host": "A.example.com:8080"}).Header().Get("Age"), Not(Equals), "")
+}
@@ -71,14 +71,12 @@ func (c Chain) Append(handlers ...Middleware) Chain {
 //     // requests in ext2Chain go m1 -> m2 -> m3 -> m4
 //
 // Another example:
-//  aHtmlAfterNosurf := webapp.New(m2)
-// 	aHtml := webapp.New(m1, func(h http.Handler) http.Handler {
-// 		csrf := nosurf.New(h)
-// 		csrf.SetFailureHandler(aHtmlAfterNosurf.ThenFunc(csrfFail))
-// 		return csrf
-// 	}).Extend(aHtmlAfterNosurf)
-//		// requests to aHtml hitting nosurfs success handler go m1 -> nosurf -> m2 -> target-handler
-//		// requests to aHtml hitting nosurfs failure handler go m1 -> nosurf -> m2 -> csrfFail
+//  aHtmlAfterCSRF := webapp.NewChain(m2)
+//  csrfConfig := webapp.DefaultCSRFConfig()
+//  csrfConfig.FailureHandler = aHtmlAfterCSRF.Then(csrfFail)
+//  aHtml := webapp.NewChain(m1, webapp.CSRF(csrfConfig)).Extend(aHtmlAfterCSRF)
+//		// requests to aHtml passing the csrf check go m1 -> csrf -> m2 -> target-handler
+//		// requests to aHtml failing the csrf check go m1 -> csrf -> m2 -> csrfFail
 func (c Chain) Extend(chain Chain) Chain {
 	return c.Append(chain...)
 }
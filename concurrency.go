@@ -0,0 +1,139 @@
+package webapp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimitConfig configures the ConcurrencyLimit middleware
+type ConcurrencyLimitConfig struct {
+	// MaxInFlight is the number of requests handled at the same time
+	MaxInFlight int
+
+	// MaxQueue is the number of requests waiting for a free slot, when the
+	// queue is full new requests are shed immediately
+	MaxQueue int
+
+	// QueueTimeout is the max time a request waits in the queue, 0 waits until the request is canceled
+	QueueTimeout time.Duration
+
+	// RetryAfter is sent in the Retry-After header when a request is shed, defaults to 1 second
+	RetryAfter time.Duration
+
+	// ShedHandler is called when a request is shed, defaults to a 503 response
+	ShedHandler ContextHandler
+
+	// OnQueue is called with the new queue length every time a request enters or leaves the queue
+	OnQueue func(length int)
+
+	// OnInFlight is called with the new number of in flight requests every time a request starts or ends
+	OnInFlight func(inFlight int)
+}
+
+// ConcurrencyLimit is a middleware capping the number of requests in flight.
+// All routes using the returned middleware share the same limit, use it with
+// App.Use to limit globally or with RouteGroup.Group to limit a group.
+// Requests exceeding the limit wait in a bounded queue until a slot frees up,
+// the queue timeout passes or the request is canceled. Requests that can not be
+// served are shed with a 503 and a Retry-After header.
+func ConcurrencyLimit(config ConcurrencyLimitConfig) Middleware {
+	if config.MaxInFlight <= 0 {
+		panic("concurrency limit requires a positive max in flight")
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+
+	shedHandler := config.ShedHandler
+	if shedHandler == nil {
+		shedHandler = defaultShedHandler
+	}
+	retryAfter := strconv.Itoa(ceilSeconds(config.RetryAfter))
+
+	slots := make(chan struct{}, config.MaxInFlight)
+	var queued, inFlight int64
+
+	enqueue := func() bool {
+		for {
+			length := atomic.LoadInt64(&queued)
+			if length >= int64(config.MaxQueue) {
+				return false
+			}
+			if atomic.CompareAndSwapInt64(&queued, length, length+1) {
+				if config.OnQueue != nil {
+					config.OnQueue(int(length + 1))
+				}
+				return true
+			}
+		}
+	}
+
+	dequeue := func() {
+		length := atomic.AddInt64(&queued, -1)
+		if config.OnQueue != nil {
+			config.OnQueue(int(length))
+		}
+	}
+
+	acquire := func(req *http.Request) bool {
+		select {
+		case slots <- struct{}{}:
+			return true
+		default:
+		}
+
+		if !enqueue() {
+			return false
+		}
+		defer dequeue()
+
+		waitCtx := req.Context()
+		if config.QueueTimeout > 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(waitCtx, config.QueueTimeout)
+			defer cancel()
+		}
+
+		select {
+		case slots <- struct{}{}:
+			return true
+		case <-waitCtx.Done():
+			return false
+		}
+	}
+
+	running := func(delta int64) {
+		n := atomic.AddInt64(&inFlight, delta)
+		if config.OnInFlight != nil {
+			config.OnInFlight(int(n))
+		}
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			if !acquire(req) {
+				rw.Header().Set("Retry-After", retryAfter)
+				shedHandler(ctx, rw, req)
+				return
+			}
+
+			running(1)
+			defer func() {
+				<-slots
+				running(-1)
+			}()
+
+			next(ctx, rw, req)
+		}
+	}
+}
+
+func defaultShedHandler(_ context.Context, rw http.ResponseWriter, _ *http.Request) {
+	http.Error(rw,
+		http.StatusText(http.StatusServiceUnavailable),
+		http.StatusServiceUnavailable,
+	)
+}
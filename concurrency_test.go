@@ -0,0 +1,129 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type ConcurrencyLimitSuite struct{}
+
+var _ = Suite(&ConcurrencyLimitSuite{})
+
+func (s *ConcurrencyLimitSuite) TestConcurrencyLimit(c *C) {
+	// blocking is the handler of the request keeping the route busy, lengths receives the queue lengths
+	var blocking ContextHandler
+	var lengths chan int
+	onQueue := func(length int) {
+		lengths <- length
+	}
+
+	tests := []struct {
+		path       string
+		canceled   bool
+		queued     bool // the request is queued until the busy request is done
+		code       int
+		retryAfter string
+		prepare    func(RouteGroup)
+	}{
+		{
+			// shed when the queue is full
+			path:       "/",
+			code:       503,
+			retryAfter: "1",
+			prepare: func(rg RouteGroup) {
+				rg.With(ConcurrencyLimit(ConcurrencyLimitConfig{MaxInFlight: 1})).GET("/", blocking)
+			},
+		}, {
+			path:   "/",
+			queued: true,
+			code:   200,
+			prepare: func(rg RouteGroup) {
+				rg.With(ConcurrencyLimit(ConcurrencyLimitConfig{MaxInFlight: 1, MaxQueue: 1, OnQueue: onQueue})).GET("/", blocking)
+			},
+		}, {
+			// queue timeout
+			path:       "/",
+			code:       503,
+			retryAfter: "5",
+			prepare: func(rg RouteGroup) {
+				rg.With(ConcurrencyLimit(ConcurrencyLimitConfig{
+					MaxInFlight:  1,
+					MaxQueue:     1,
+					QueueTimeout: 10 * time.Millisecond,
+					RetryAfter:   5 * time.Second,
+				})).GET("/", blocking)
+			},
+		}, {
+			// the request context is canceled while queued
+			path:     "/",
+			canceled: true,
+			code:     503,
+			prepare: func(rg RouteGroup) {
+				rg.With(ConcurrencyLimit(ConcurrencyLimitConfig{MaxInFlight: 1, MaxQueue: 1})).GET("/", blocking)
+			},
+		}, {
+			// the limit is shared between the routes
+			path: "/other",
+			code: 503,
+			prepare: func(rg RouteGroup) {
+				limited := rg.With(ConcurrencyLimit(ConcurrencyLimitConfig{MaxInFlight: 1}))
+				limited.GET("/", blocking)
+				limited.GET("/other", finalHandler)
+			},
+		},
+	}
+
+	for index, test := range tests {
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+		lengths = make(chan int, 2)
+		blocking = func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			started <- struct{}{}
+			<-release
+			rw.Write([]byte("H"))
+		}
+		rg := newRouteGroup(httprouter.New())
+		test.prepare(rg)
+
+		go doTestRequest(rg, "GET", "/")
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.canceled {
+			cancel()
+		}
+		req, _ := http.NewRequestWithContext(ctx, "GET", test.path, nil)
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			rw := httptest.NewRecorder()
+			rg.ServeHTTP(rw, req)
+			done <- rw
+		}()
+
+		if test.queued {
+			c.Check(<-lengths, Equals, 1, Commentf("test %d failed", index))
+			close(release)
+		}
+		response := <-done
+		if !test.queued {
+			close(release)
+		}
+		cancel()
+
+		c.Check(response.Code, Equals, test.code, Commentf("test %d failed", index))
+		if test.retryAfter != "" {
+			c.Check(response.Header().Get("Retry-After"), Equals, test.retryAfter, Commentf("test %d failed", index))
+		}
+		if test.queued {
+			c.Check(<-lengths, Equals, 0, Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *ConcurrencyLimitSuite) TestPanicsOnInvalidConfig(c *C) {
+	c.Assert(func() { ConcurrencyLimit(ConcurrencyLimitConfig{}) }, PanicMatches, "concurrency limit requires a positive max in flight")
+}
@@ -0,0 +1,200 @@
+package webapp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag is a middleware that adds an ETag to successful GET responses by hashing
+// the buffered body and answers conditional GET and HEAD requests with a 304 or 412.
+// Responses already having an ETag or Last-Modified header are only evaluated.
+// HEAD responses have no body to hash, only the validators set by the handler are evaluated.
+// When the response is not buffered by the Buffer middleware ETag buffers it itself.
+//
+// Unsafe methods are not evaluated after the handler ran, use CheckPreconditions
+// in the handler before changing the resource.
+func ETag(weak bool) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			if req.Method != "GET" && req.Method != "HEAD" {
+				next(ctx, rw, req)
+				return
+			}
+
+			buffered, ok := rw.(BufferedResponseWriter)
+			var owned *bufferedResponseWriter
+			if !ok || !buffered.Buffered() {
+				owned = newBufferedResponseWriter(rw, 0)
+				buffered = owned
+			}
+
+			next(ctx, buffered, req)
+			applyETag(buffered, req, weak)
+
+			if owned != nil {
+				owned.flush()
+			}
+		}
+	}
+}
+
+func applyETag(rw BufferedResponseWriter, req *http.Request, weak bool) {
+	if !rw.Buffered() || rw.Status() != http.StatusOK {
+		return
+	}
+
+	header := rw.Header()
+	if header.Get("ETag") == "" && req.Method == "GET" {
+		sum := sha256.Sum256(rw.Body())
+		SetETag(rw, hex.EncodeToString(sum[:16]), weak)
+	}
+
+	status := evaluatePreconditions(req, header.Get("ETag"), lastModified(header))
+	if status == 0 {
+		return
+	}
+
+	kept := header.Clone()
+	rw.Reset()
+	for key, values := range kept {
+		header[key] = values
+	}
+	writePreconditionStatus(rw, status)
+}
+
+// SetETag sets the ETag header, the tag is quoted and prefixed with W/ for weak tags
+func SetETag(rw http.ResponseWriter, tag string, weak bool) {
+	tag = `"` + tag + `"`
+	if weak {
+		tag = "W/" + tag
+	}
+	rw.Header().Set("ETag", tag)
+}
+
+// SetLastModified sets the Last-Modified header
+func SetLastModified(rw http.ResponseWriter, modified time.Time) {
+	rw.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+}
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since, If-None-Match and
+// If-Modified-Since request headers against the ETag and Last-Modified response headers.
+// When a precondition fails a 304 (GET and HEAD) or 412 is written and true is returned,
+// the handler should stop handling the request.
+//
+//     webapp.SetETag(rw, article.Version, false)
+//     webapp.SetLastModified(rw, article.Updated)
+//     if webapp.CheckPreconditions(rw, req) {
+//         return
+//     }
+func CheckPreconditions(rw http.ResponseWriter, req *http.Request) bool {
+	status := evaluatePreconditions(req, rw.Header().Get("ETag"), lastModified(rw.Header()))
+	if status == 0 {
+		return false
+	}
+	writePreconditionStatus(rw, status)
+	return true
+}
+
+func writePreconditionStatus(rw http.ResponseWriter, status int) {
+	header := rw.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	rw.WriteHeader(status)
+}
+
+func lastModified(header http.Header) time.Time {
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return modified
+}
+
+// evaluatePreconditions follows the order of RFC 7232 section 6, it returns
+// 0 when the request can be handled, 304 or 412 otherwise
+func evaluatePreconditions(req *http.Request, etag string, modified time.Time) int {
+	safe := req.Method == "GET" || req.Method == "HEAD"
+
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil && !modified.IsZero() {
+		if modified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && safe && !modified.IsZero() {
+		if !modified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag reports if one of the tags in the header matches the etag,
+// a strong comparison does not match weak tags. The preconditions are evaluated
+// for existing resources, * matches them also when they have no etag.
+func matchETag(header, etag string, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+
+	for _, tag := range scanETags(header) {
+		if strong {
+			if !isWeakETag(tag) && !isWeakETag(etag) && tag == etag {
+				return true
+			}
+		} else if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isWeakETag(tag string) bool {
+	return strings.HasPrefix(tag, "W/")
+}
+
+// scanETags splits a list of entity tags, commas inside quoted tags are kept
+func scanETags(header string) []string {
+	var tags []string
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return tags
+		}
+
+		prefix := ""
+		if strings.HasPrefix(header, "W/") {
+			prefix = "W/"
+			header = header[2:]
+		}
+		if len(header) < 2 || header[0] != '"' {
+			return tags
+		}
+
+		end := strings.IndexByte(header[1:], '"')
+		if end < 0 {
+			return tags
+		}
+		tags = append(tags, prefix+header[:end+2])
+		header = header[end+2:]
+	}
+}
@@ -0,0 +1,234 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ConditionalSuite struct{}
+
+var _ = Suite(&ConditionalSuite{})
+
+func etagHelloHandler(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Write([]byte("hello"))
+}
+
+func (s *ConditionalSuite) TestETag(c *C) {
+	tests := []struct {
+		headers     map[string]string // {etag} is replaced by the ETag of a previous response, {tag} by it without W/
+		code        int
+		body        string
+		etag        string
+		contentType string
+		prepare     func(RouteGroup)
+	}{
+		{
+			code:        200,
+			body:        "hello",
+			etag:        `"[0-9a-f]{32}"`,
+			contentType: "text/plain",
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(false)).GET("/", etagHelloHandler)
+			},
+		}, {
+			// not modified keeps the ETag and drops the content headers
+			headers: map[string]string{"If-None-Match": `"other", {etag}`},
+			code:    304,
+			etag:    `"[0-9a-f]{32}"`,
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(false)).GET("/", etagHelloHandler)
+			},
+		}, {
+			headers:     map[string]string{"If-None-Match": `"other"`},
+			code:        200,
+			body:        "hello",
+			etag:        `"[0-9a-f]{32}"`,
+			contentType: "text/plain",
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(false)).GET("/", etagHelloHandler)
+			},
+		}, {
+			headers: map[string]string{"If-Match": "{etag}"},
+			code:    200,
+			body:    "hello",
+			etag:    `"[0-9a-f]{32}"`,
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(false)).GET("/", etagHelloHandler)
+			},
+		}, {
+			headers: map[string]string{"If-Match": "*"},
+			code:    200,
+			body:    "hello",
+			etag:    `"[0-9a-f]{32}"`,
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(false)).GET("/", etagHelloHandler)
+			},
+		}, {
+			headers: map[string]string{"If-Match": `"other"`},
+			code:    412,
+			etag:    `"[0-9a-f]{32}"`,
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(false)).GET("/", etagHelloHandler)
+			},
+		}, {
+			// weak tags match the strong tag with a weak comparison
+			headers: map[string]string{"If-None-Match": "{tag}"},
+			code:    304,
+			etag:    `W/"[0-9a-f]{32}"`,
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(true)).GET("/", etagHelloHandler)
+			},
+		}, {
+			// and never with the strong comparison of If-Match
+			headers: map[string]string{"If-Match": "{etag}"},
+			code:    412,
+			etag:    `W/"[0-9a-f]{32}"`,
+			prepare: func(rg RouteGroup) {
+				rg.With(ETag(true)).GET("/", etagHelloHandler)
+			},
+		},
+	}
+
+	for index, test := range tests {
+		rg := newRouteGroup(httprouter.New())
+		test.prepare(rg)
+
+		etag := doTestRequest(rg, "GET", "/").Header().Get("ETag")
+		replacer := strings.NewReplacer("{etag}", etag, "{tag}", strings.TrimPrefix(etag, "W/"))
+		headers := make(map[string]string)
+		for key, value := range test.headers {
+			headers[key] = replacer.Replace(value)
+		}
+		response := doTestRequest(rg, "GET", "/", headers)
+
+		c.Check(response.Code, Equals, test.code, Commentf("test %d failed", index))
+		c.Check(response.Body.String(), Equals, test.body, Commentf("test %d failed", index))
+		c.Check(response.Header().Get("ETag"), Matches, test.etag, Commentf("test %d failed", index))
+		c.Check(response.Header().Get("ETag"), Equals, etag, Commentf("test %d failed", index))
+		if test.code != 200 || test.contentType != "" {
+			c.Check(response.Header().Get("Content-Type"), Equals, test.contentType, Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *ConditionalSuite) TestETagHead(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	etagged := rg.With(ETag(false))
+	bodyOnGet := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		if req.Method == "GET" {
+			rw.Write([]byte("hello"))
+		}
+	}
+	etagged.GET("/", bodyOnGet)
+	etagged.HEAD("/", bodyOnGet)
+	etagged.HEAD("/tagged", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		SetETag(rw, "v1", false)
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	etag := doTestRequest(rg, "GET", "/").Header().Get("ETag")
+	c.Assert(etag, Not(Equals), "")
+
+	response := doTestRequest(rg, "HEAD", "/")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Header().Get("ETag"), Equals, "")
+	c.Assert(doTestRequest(rg, "HEAD", "/", map[string]string{"If-None-Match": etag}).Code, Equals, 200)
+
+	c.Assert(doTestRequest(rg, "HEAD", "/tagged", map[string]string{"If-None-Match": `"v1"`}).Code, Equals, 304)
+}
+
+func (s *ConditionalSuite) TestETagSkipsErrorResponses(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(ETag(false)).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "failed", http.StatusInternalServerError)
+	})
+
+	response := doTestRequest(rg, "GET", "/", map[string]string{"If-None-Match": "*"})
+
+	c.Assert(response.Code, Equals, 500)
+	c.Assert(response.Header().Get("ETag"), Equals, "")
+}
+
+func (s *ConditionalSuite) TestETagKeepsHandlerETag(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Buffer(0), ETag(false)).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		SetETag(rw, "v1", false)
+		rw.Write([]byte("hello"))
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+	c.Assert(response.Header().Get("ETag"), Equals, `"v1"`)
+
+	response = doTestRequest(rg, "GET", "/", map[string]string{"If-None-Match": `"v1"`})
+	c.Assert(response.Code, Equals, 304)
+	c.Assert(response.Header().Get("Content-Length"), Equals, "")
+}
+
+func (s *ConditionalSuite) TestLastModified(c *C) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	rg := newRouteGroup(httprouter.New())
+	rg.With(ETag(false)).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		SetLastModified(rw, modified)
+		rw.Write([]byte("hello"))
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+	c.Assert(response.Header().Get("Last-Modified"), Equals, "Thu, 02 Jan 2020 03:04:05 GMT")
+
+	since := map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}
+	c.Assert(doTestRequest(rg, "GET", "/", since).Code, Equals, 304)
+
+	earlier := map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}
+	c.Assert(doTestRequest(rg, "GET", "/", earlier).Code, Equals, 200)
+
+	unmodified := map[string]string{"If-Unmodified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}
+	c.Assert(doTestRequest(rg, "GET", "/", unmodified).Code, Equals, 412)
+
+	ignored := map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)}
+	c.Assert(doTestRequest(rg, "GET", "/", ignored).Code, Equals, 200)
+}
+
+func (s *ConditionalSuite) TestCheckPreconditionsUnsafeMethod(c *C) {
+	updated := false
+	rg := newRouteGroup(httprouter.New())
+	rg.With(ETag(false)).PUT("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		SetETag(rw, "v1", false)
+		if CheckPreconditions(rw, req) {
+			return
+		}
+		updated = true
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	c.Assert(doTestRequest(rg, "PUT", "/", map[string]string{"If-Match": `"v0"`}).Code, Equals, 412)
+	c.Assert(doTestRequest(rg, "PUT", "/", map[string]string{"If-None-Match": "*"}).Code, Equals, 412)
+	c.Assert(updated, Equals, false)
+
+	c.Assert(doTestRequest(rg, "PUT", "/", map[string]string{"If-Match": `"v1"`}).Code, Equals, 204)
+	c.Assert(updated, Equals, true)
+}
+
+func (s *ConditionalSuite) TestCheckPreconditionsWithoutETag(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.PUT("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		if CheckPreconditions(rw, req) {
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	c.Assert(doTestRequest(rg, "PUT", "/", map[string]string{"If-Match": "*"}).Code, Equals, 204)
+	c.Assert(doTestRequest(rg, "PUT", "/", map[string]string{"If-Match": `"v1"`}).Code, Equals, 412)
+	c.Assert(doTestRequest(rg, "PUT", "/", map[string]string{"If-None-Match": "*"}).Code, Equals, 412)
+}
+
+func (s *ConditionalSuite) TestScanETags(c *C) {
+	c.Assert(scanETags(`"a", W/"b,c" ,"d"`), DeepEquals, []string{`"a"`, `W/"b,c"`, `"d"`})
+	c.Assert(scanETags(`broken`), IsNil)
+}
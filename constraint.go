@@ -0,0 +1,205 @@
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ParamConstraint reports if the value of a route parameter is accepted
+type ParamConstraint func(value string) bool
+
+var constraints = struct {
+	sync.RWMutex
+	named map[string]ParamConstraint
+}{
+	named: map[string]ParamConstraint{
+		"int":   regexp.MustCompile(`^-?[0-9]+$`).MatchString,
+		"uint":  regexp.MustCompile(`^[0-9]+$`).MatchString,
+		"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+		"alnum": regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+		"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	},
+}
+
+// RegisterConstraint makes the constraint available by name in route paths, e.g. :code<country>.
+// The built in constraints are int, uint, alpha, alnum and uuid, other constraints in
+// a path are used as regular expressions matching the complete value.
+func RegisterConstraint(name string, constraint ParamConstraint) {
+	constraints.Lock()
+	defer constraints.Unlock()
+
+	constraints.named[name] = constraint
+}
+
+func lookupConstraint(expression string) (ParamConstraint, error) {
+	constraints.RLock()
+	constraint, ok := constraints.named[expression]
+	constraints.RUnlock()
+	if ok {
+		return constraint, nil
+	}
+
+	pattern, err := regexp.Compile("^(?:" + expression + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return pattern.MatchString, nil
+}
+
+// routePattern is a route path with the constraints removed
+type routePattern struct {
+	// path is the path for the router, shape is the path without parameter names
+	path  string
+	shape string
+
+	names       []string
+	constraints []ParamConstraint
+}
+
+func (pattern *routePattern) constrained() bool {
+	for _, constraint := range pattern.constraints {
+		if constraint != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRoutePattern splits the constraints from the parameters, a constraint
+// ends at the > followed by a slash or the end of the path
+func parseRoutePattern(routePath string) (*routePattern, error) {
+	pattern := &routePattern{}
+	var path, shape strings.Builder
+
+	for i := 0; i < len(routePath); {
+		c := routePath[i]
+		if c != ':' && c != '*' {
+			path.WriteByte(c)
+			shape.WriteByte(c)
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(routePath) && routePath[end] != '/' && routePath[end] != '<' {
+			end++
+		}
+		name := routePath[i+1 : end]
+		if name == "" {
+			return nil, fmt.Errorf("route %s: parameter without a name", routePath)
+		}
+		path.WriteString(routePath[i:end])
+		shape.WriteByte(c)
+
+		var constraint ParamConstraint
+		if end < len(routePath) && routePath[end] == '<' {
+			close := -1
+			for j := end + 1; j < len(routePath); j++ {
+				if routePath[j] == '>' && (j+1 == len(routePath) || routePath[j+1] == '/') {
+					close = j
+					break
+				}
+			}
+			if close < 0 {
+				return nil, fmt.Errorf("route %s: constraint of parameter %s is not closed", routePath, name)
+			}
+
+			var err error
+			constraint, err = lookupConstraint(routePath[end+1 : close])
+			if err != nil {
+				return nil, fmt.Errorf("route %s: invalid constraint of parameter %s: %v", routePath, name, err)
+			}
+			end = close + 1
+		}
+
+		pattern.names = append(pattern.names, name)
+		pattern.constraints = append(pattern.constraints, constraint)
+		i = end
+	}
+
+	pattern.path = path.String()
+	pattern.shape = shape.String()
+	return pattern, nil
+}
+
+// constrainedRoute dispatches the requests for routes with the same shape to the first route
+// whose constraints accept the parameters, the route without constraints is used as fallback
+type constrainedRoute struct {
+	group    *routeGroup
+	variants []constrainedVariant
+	fallback *constrainedVariant
+}
+
+type constrainedVariant struct {
+	pattern *routePattern
+	handler ContextHandler
+}
+
+// handleConstrained registers the route with parameters, routes with the same shape
+// are handled by the same router handle which tries their constraints
+func (group *routeGroup) handleConstrained(method string, pattern *routePattern, handler ContextHandler) {
+	key := group.host + " " + method + " " + pattern.shape
+
+	group.routes.Lock()
+	defer group.routes.Unlock()
+
+	constrained, ok := group.routes.constrained[key]
+	if !ok {
+		constrained = &constrainedRoute{group: group}
+		group.router.Handle(method, pattern.path, constrained.serve)
+		group.routes.constrained[key] = constrained
+	}
+
+	variant := constrainedVariant{
+		pattern: pattern,
+		handler: handler,
+	}
+	if pattern.constrained() {
+		constrained.variants = append(constrained.variants, variant)
+		return
+	}
+
+	if constrained.fallback != nil {
+		if existing := constrained.fallback.pattern.path; existing != pattern.path {
+			panic("path '" + pattern.path + "' conflicts with existing route '" + existing + "'")
+		}
+		panic("a handle is already registered for path '" + pattern.path + "'")
+	}
+	constrained.fallback = &variant
+}
+
+func (constrained *constrainedRoute) serve(rw http.ResponseWriter, req *http.Request, params RouteParams) {
+	for _, variant := range constrained.variants {
+		if variant.matches(params) {
+			variant.serve(rw, req, params)
+			return
+		}
+	}
+
+	if constrained.fallback != nil {
+		constrained.fallback.serve(rw, req, params)
+		return
+	}
+	constrained.group.routeNotFound(rw, req)
+}
+
+// serve calls the handler of the variant with the parameters named as in its path
+func (variant *constrainedVariant) serve(rw http.ResponseWriter, req *http.Request, params RouteParams) {
+	named := make(RouteParams, len(params))
+	for i, param := range params {
+		named[i] = RouteParam{Key: variant.pattern.names[i], Value: param.Value}
+	}
+	routeHandle(variant.handler)(rw, req, named)
+}
+
+func (variant *constrainedVariant) matches(params RouteParams) bool {
+	for i, constraint := range variant.pattern.constraints {
+		if constraint != nil && !constraint(params[i].Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"strings"
+)
+
+type ConstraintSuite struct{}
+
+var _ = Suite(&ConstraintSuite{})
+
+func paramsHandler(prefix string, names ...string) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		values := []string{prefix}
+		for _, name := range names {
+			values = append(values, Param(ctx, name))
+		}
+		rw.Write([]byte(strings.Join(values, " ")))
+	}
+}
+
+func (s *ConstraintSuite) TestConstrainedParams(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.GET("/users/:id<int>", paramsHandler("id", "id"))
+	rg.GET("/users/:name<[a-z]+>", paramsHandler("name", "name"))
+	rg.GET("/users/:id/posts", paramsHandler("posts", "id"))
+
+	c.Assert(doTestRequest(rg, "GET", "/users/42").Body.String(), Equals, "id 42")
+	c.Assert(doTestRequest(rg, "GET", "/users/john").Body.String(), Equals, "name john")
+	c.Assert(doTestRequest(rg, "GET", "/users/John").Code, Equals, 404)
+	c.Assert(doTestRequest(rg, "GET", "/users/john/posts").Body.String(), Equals, "posts john")
+}
+
+func (s *ConstraintSuite) TestUnconstrainedFallback(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.GET("/files/:id<uuid>", paramsHandler("uuid", "id"))
+	rg.GET("/files/:name", paramsHandler("any", "name"))
+
+	c.Assert(doTestRequest(rg, "GET", "/files/0b6bb2b8-1f5e-4d2c-9c43-52b7b6d3b0a1").Body.String(), Equals, "uuid 0b6bb2b8-1f5e-4d2c-9c43-52b7b6d3b0a1")
+	c.Assert(doTestRequest(rg, "GET", "/files/report.pdf").Body.String(), Equals, "any report.pdf")
+}
+
+func (s *ConstraintSuite) TestUnconstrainedRegisteredFirst(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	c.Assert(rg.Register("GET", "/users/:name", paramsHandler("name", "name")), IsNil)
+	c.Assert(rg.Register("GET", "/users/:id<int>", paramsHandler("id", "id")), IsNil)
+
+	c.Assert(doTestRequest(rg, "GET", "/users/42").Body.String(), Equals, "id 42")
+	c.Assert(doTestRequest(rg, "GET", "/users/john").Body.String(), Equals, "name john")
+	c.Assert(rg.Routes(), HasLen, 2)
+}
+
+func (s *ConstraintSuite) TestConstrainedMissUsesAppNotFound(c *C) {
+	app := New()
+	app.Use(middlewareWriter("app "))
+	app.NotFound(writeHandler("missing"))
+	app.GET("/orders/:id<uint>", paramsHandler("order", "id"))
+
+	c.Assert(doTestRequest(app, "GET", "/orders/-1").Body.String(), Equals, "app missing")
+	c.Assert(doTestRequest(app, "GET", "/unknown").Body.String(), Equals, "app missing")
+}
+
+func (s *ConstraintSuite) TestRegisterConstraint(c *C) {
+	RegisterConstraint("country", func(value string) bool {
+		return value == "nl" || value == "be"
+	})
+
+	rg := newRouteGroup(httprouter.New())
+	rg.GET("/shop/:country<country>/*path<.*\\.html>", paramsHandler("shop", "country", "path"))
+
+	c.Assert(doTestRequest(rg, "GET", "/shop/nl/products/a.html").Body.String(), Equals, "shop nl /products/a.html")
+	c.Assert(doTestRequest(rg, "GET", "/shop/de/products/a.html").Code, Equals, 404)
+	c.Assert(doTestRequest(rg, "GET", "/shop/nl/products/a.json").Code, Equals, 404)
+}
+
+func (s *ConstraintSuite) TestRegisterErrors(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	c.Assert(rg.Register("GET", "/a/:id", finalHandler), IsNil)
+
+	c.Assert(rg.Register("GET", "/a/:name", finalHandler), ErrorMatches, "route GET /a/:name: .*conflicts.*")
+	c.Assert(rg.Register("GET", "/a/:id", finalHandler), ErrorMatches, "route GET /a/:id: .*already registered.*")
+	c.Assert(rg.Register("GET", "/b/:id<[a-z>", finalHandler), ErrorMatches, "route /b/:id<\\[a-z>: invalid constraint of parameter id: .*")
+	c.Assert(rg.Register("GET", "/b/:id<int", finalHandler), ErrorMatches, "route /b/:id<int: constraint of parameter id is not closed")
+	c.Assert(rg.Routes(), HasLen, 1)
+
+	c.Assert(func() { rg.GET("/a/:name", finalHandler) }, PanicMatches, "route GET /a/:name: .*")
+}
+
+func (s *ConstraintSuite) TestParseRoutePattern(c *C) {
+	pattern, err := parseRoutePattern("/users/:id<int>/files/*path<.+>")
+
+	c.Assert(err, IsNil)
+	c.Assert(pattern.path, Equals, "/users/:id/files/*path")
+	c.Assert(pattern.shape, Equals, "/users/:/files/*")
+	c.Assert(pattern.names, DeepEquals, []string{"id", "path"})
+	c.Assert(pattern.constrained(), Equals, true)
+}
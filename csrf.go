@@ -0,0 +1,209 @@
+package webapp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"path"
+)
+
+type csrfKey int
+
+const csrfTokenKey csrfKey = iota
+
+const csrfTokenLength = 32
+
+var (
+	// ErrCSRFTokenMissing is stored in the context when the request has no csrf cookie or token
+	ErrCSRFTokenMissing = errors.New("csrf token missing")
+
+	// ErrCSRFTokenInvalid is stored in the context when the token does not match the cookie
+	ErrCSRFTokenInvalid = errors.New("csrf token invalid")
+)
+
+// CSRFConfig configures the CSRF middleware.
+type CSRFConfig struct {
+	// CookieName is the name of the cookie holding the token
+	CookieName   string
+	CookiePath   string
+	CookieDomain string
+	CookieMaxAge int
+	CookieSecure bool
+	SameSite     http.SameSite
+
+	// HeaderName is the request header checked for the token
+	HeaderName string
+
+	// FormField is the form field checked for the token when the header is absent
+	FormField string
+
+	// SafeMethods are the methods that are not checked
+	SafeMethods []string
+
+	// Exempt are the request paths that are not checked, path.Match patterns are allowed
+	Exempt []string
+
+	// FailureHandler is called when the token check fails, the reason is available by Error(ctx).
+	// When no handler is set a 403 is returned.
+	FailureHandler ContextHandler
+}
+
+// DefaultCSRFConfig returns a config using a double submit cookie named csrf_token,
+// the cookie is only sent over secure connections when Env() is not development.
+func DefaultCSRFConfig() CSRFConfig {
+	return CSRFConfig{
+		CookieName:   "csrf_token",
+		CookiePath:   "/",
+		CookieMaxAge: 365 * 24 * 60 * 60,
+		CookieSecure: Env() != Development,
+		SameSite:     http.SameSiteLaxMode,
+		HeaderName:   "X-CSRF-Token",
+		FormField:    "csrf_token",
+		SafeMethods:  []string{"GET", "HEAD", "OPTIONS", "TRACE"},
+	}
+}
+
+// CSRF is a middleware protecting against cross site request forgery using a double submit cookie.
+// Every request gets a token stored in a cookie, requests with an unsafe method need to
+// send the same token back in the header or the form field.
+// Retrieve the token for use in templates or javascript using CSRFToken(ctx).
+func CSRF(config CSRFConfig) Middleware {
+	safeMethods := make(map[string]bool, len(config.SafeMethods))
+	for _, method := range config.SafeMethods {
+		safeMethods[method] = true
+	}
+
+	failureHandler := config.FailureHandler
+	if failureHandler == nil {
+		failureHandler = defaultCSRFFailureHandler
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Add("Vary", "Cookie")
+
+			token := csrfTokenFromCookie(req, config.CookieName)
+			if token == nil {
+				token = make([]byte, csrfTokenLength)
+				rand.Read(token)
+				http.SetCookie(rw, &http.Cookie{
+					Name:     config.CookieName,
+					Value:    base64.RawURLEncoding.EncodeToString(token),
+					Path:     config.CookiePath,
+					Domain:   config.CookieDomain,
+					MaxAge:   config.CookieMaxAge,
+					Secure:   config.CookieSecure,
+					HttpOnly: true,
+					SameSite: config.SameSite,
+				})
+			}
+
+			ctx = context.WithValue(ctx, csrfTokenKey, token)
+
+			if safeMethods[req.Method] || csrfExempt(config.Exempt, req.URL.Path) {
+				next(ctx, rw, req)
+				return
+			}
+
+			if err := verifyCSRFToken(req, config, token); err != nil {
+				ctx = context.WithValue(ctx, errorKey, err)
+				failureHandler(ctx, rw, req)
+				return
+			}
+
+			next(ctx, rw, req)
+		}
+	}
+}
+
+// CSRFToken retrieves the masked csrf token from the context.
+// A new masked value is returned on every call, an empty string is returned
+// when the CSRF middleware is not used.
+func CSRFToken(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if token, ok := ctx.Value(csrfTokenKey).([]byte); ok {
+		return base64.RawURLEncoding.EncodeToString(maskCSRFToken(token))
+	}
+
+	return ""
+}
+
+func csrfTokenFromCookie(req *http.Request, name string) []byte {
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return nil
+	}
+
+	token, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(token) != csrfTokenLength {
+		return nil
+	}
+	return token
+}
+
+func verifyCSRFToken(req *http.Request, config CSRFConfig, token []byte) error {
+	if _, err := req.Cookie(config.CookieName); err != nil {
+		return ErrCSRFTokenMissing
+	}
+
+	sent := req.Header.Get(config.HeaderName)
+	if sent == "" && config.FormField != "" {
+		sent = req.PostFormValue(config.FormField)
+	}
+	if sent == "" {
+		return ErrCSRFTokenMissing
+	}
+
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != csrfTokenLength*2 {
+		return ErrCSRFTokenInvalid
+	}
+
+	if subtle.ConstantTimeCompare(unmaskCSRFToken(masked), token) != 1 {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+// maskCSRFToken xors the token with a one time pad to prevent BREACH attacks,
+// the pad is prepended to the result
+func maskCSRFToken(token []byte) []byte {
+	masked := make([]byte, len(token)*2)
+	pad := masked[:len(token)]
+	rand.Read(pad)
+	for i := range token {
+		masked[len(token)+i] = token[i] ^ pad[i]
+	}
+	return masked
+}
+
+func unmaskCSRFToken(masked []byte) []byte {
+	size := len(masked) / 2
+	token := make([]byte, size)
+	for i := range token {
+		token[i] = masked[i] ^ masked[size+i]
+	}
+	return token
+}
+
+func csrfExempt(patterns []string, urlPath string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, urlPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultCSRFFailureHandler(_ context.Context, rw http.ResponseWriter, _ *http.Request) {
+	http.Error(rw,
+		http.StatusText(http.StatusForbidden),
+		http.StatusForbidden,
+	)
+}
@@ -0,0 +1,170 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+type CSRFSuite struct{}
+
+var _ = Suite(&CSRFSuite{})
+
+// csrfTokenHandler writes the csrf token of the request
+func csrfTokenHandler(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	rw.Write([]byte(CSRFToken(ctx)))
+}
+
+func csrfCookie(response *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == "csrf_token" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func (s *CSRFSuite) TestCSRF(c *C) {
+	tests := []struct {
+		method   string
+		path     string
+		token    string // how the token of a previous GET /form is sent: header, form or other for the token of another cookie
+		code     int
+		response string
+		cookie   bool
+		prepare  func(RouteGroup)
+	}{
+		{
+			// safe methods get a cookie and the token
+			method: "GET",
+			path:   "/form",
+			code:   200,
+			cookie: true,
+			prepare: func(rg RouteGroup) {
+				rg.With(CSRF(DefaultCSRFConfig())).GET("/form", csrfTokenHandler)
+			},
+		}, {
+			method: "POST",
+			path:   "/form",
+			code:   403,
+			prepare: func(rg RouteGroup) {
+				rg.With(CSRF(DefaultCSRFConfig())).POST("/form", finalHandler)
+			},
+		}, {
+			method:   "POST",
+			path:     "/form",
+			token:    "header",
+			code:     200,
+			response: "H",
+			prepare: func(rg RouteGroup) {
+				csrf := rg.With(CSRF(DefaultCSRFConfig()))
+				csrf.GET("/form", csrfTokenHandler)
+				csrf.POST("/form", finalHandler)
+			},
+		}, {
+			method:   "POST",
+			path:     "/form",
+			token:    "form",
+			code:     200,
+			response: "H",
+			prepare: func(rg RouteGroup) {
+				csrf := rg.With(CSRF(DefaultCSRFConfig()))
+				csrf.GET("/form", csrfTokenHandler)
+				csrf.POST("/form", finalHandler)
+			},
+		}, {
+			method:   "POST",
+			path:     "/form",
+			token:    "other",
+			code:     400,
+			response: ErrCSRFTokenInvalid.Error(),
+			prepare: func(rg RouteGroup) {
+				config := DefaultCSRFConfig()
+				config.FailureHandler = func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+					rw.WriteHeader(http.StatusBadRequest)
+					rw.Write([]byte(Error(ctx).Error()))
+				}
+				csrf := rg.With(CSRF(config))
+				csrf.GET("/form", csrfTokenHandler)
+				csrf.POST("/form", finalHandler)
+			},
+		}, {
+			// exempt paths are not checked
+			method: "POST",
+			path:   "/hooks/github",
+			code:   200,
+			prepare: func(rg RouteGroup) {
+				config := DefaultCSRFConfig()
+				config.Exempt = []string{"/hooks/*"}
+				rg.With(CSRF(config)).POST("/hooks/github", finalHandler)
+			},
+		}, {
+			// custom safe methods
+			method: "POST",
+			path:   "/form",
+			code:   200,
+			prepare: func(rg RouteGroup) {
+				config := DefaultCSRFConfig()
+				config.SafeMethods = []string{"GET", "POST"}
+				rg.With(CSRF(config)).POST("/form", finalHandler)
+			},
+		},
+	}
+
+	for index, test := range tests {
+		rg := newRouteGroup(httprouter.New())
+		test.prepare(rg)
+
+		var cookie *http.Cookie
+		var token string
+		if test.token != "" {
+			issued := doTestRequest(rg, "GET", "/form")
+			cookie, token = csrfCookie(issued), issued.Body.String()
+			if test.token == "other" {
+				cookie = csrfCookie(doTestRequest(rg, "GET", "/form"))
+			}
+		}
+
+		var body io.Reader
+		if test.token == "form" {
+			body = strings.NewReader(url.Values{"csrf_token": {token}}.Encode())
+		}
+		req, _ := http.NewRequest(test.method, test.path, body)
+		if test.token == "form" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rw := httptest.NewRecorder()
+		rg.ServeHTTP(rw, req)
+
+		c.Check(rw.Code, Equals, test.code, Commentf("test %d failed", index))
+		if test.response != "" {
+			c.Check(rw.Body.String(), Equals, test.response, Commentf("test %d failed", index))
+		}
+		if test.cookie {
+			c.Check(csrfCookie(rw), NotNil, Commentf("test %d failed", index))
+			c.Check(rw.Body.String(), Not(Equals), "", Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *CSRFSuite) TestTokenIsMaskedPerCall(c *C) {
+	token := []byte(strings.Repeat("a", csrfTokenLength))
+	ctx := context.WithValue(context.Background(), csrfTokenKey, token)
+
+	c.Assert(CSRFToken(ctx), Not(Equals), CSRFToken(ctx))
+}
+
+func (s *CSRFSuite) TestCSRFTokenWithoutMiddleware(c *C) {
+	c.Assert(CSRFToken(nil), Equals, "")
+	c.Assert(CSRFToken(context.Background()), Equals, "")
+}
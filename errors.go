@@ -0,0 +1,77 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type errorsKey int
+
+const errorHandlerKey errorsKey = iota
+
+// HTTPError is an error with the http status code to respond with
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+// NewHTTPError creates a new HTTPError, when err is nil the status text is used as error
+func NewHTTPError(status int, err error) *HTTPError {
+	if err == nil {
+		err = errors.New(http.StatusText(status))
+	}
+	return &HTTPError{Status: status, Err: err}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorStatus returns the http status code of the error,
+// errors not wrapping a HTTPError result in a 500
+func ErrorStatus(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorHandler is a middleware that sets the handler called by HandleError.
+// The handler can retrieve the error using Error(ctx) and the status using ErrorStatus.
+//
+//     app.Use(webapp.ErrorHandler(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+//         err := webapp.Error(ctx)
+//         rw.WriteHeader(webapp.ErrorStatus(err))
+//         json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+//     }))
+func ErrorHandler(handler ContextHandler) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			next(context.WithValue(ctx, errorHandlerKey, handler), rw, req)
+		}
+	}
+}
+
+// HandleError is the central error path for middleware and handlers.
+// The error is stored in the context and the handler set by the ErrorHandler
+// middleware is called, without a handler the status text is written.
+func HandleError(ctx context.Context, rw http.ResponseWriter, req *http.Request, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, errorKey, err)
+
+	if handler, ok := ctx.Value(errorHandlerKey).(ContextHandler); ok && handler != nil {
+		handler(ctx, rw, req)
+		return
+	}
+
+	status := ErrorStatus(err)
+	http.Error(rw, http.StatusText(status), status)
+}
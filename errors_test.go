@@ -0,0 +1,57 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+)
+
+type ErrorsSuite struct{}
+
+var _ = Suite(&ErrorsSuite{})
+
+func (s *ErrorsSuite) TestHTTPError(c *C) {
+	cause := errors.New("cause")
+	err := NewHTTPError(http.StatusForbidden, cause)
+
+	c.Assert(err.Error(), Equals, "cause")
+	c.Assert(errors.Is(err, cause), Equals, true)
+	c.Assert(NewHTTPError(http.StatusNotFound, nil).Error(), Equals, "Not Found")
+}
+
+func (s *ErrorsSuite) TestErrorStatus(c *C) {
+	c.Assert(ErrorStatus(NewHTTPError(http.StatusForbidden, nil)), Equals, 403)
+	c.Assert(ErrorStatus(fmt.Errorf("wrapped: %w", NewHTTPError(http.StatusConflict, nil))), Equals, 409)
+	c.Assert(ErrorStatus(errors.New("plain")), Equals, 500)
+}
+
+func (s *ErrorsSuite) TestHandleErrorWithoutHandler(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		HandleError(ctx, rw, req, NewHTTPError(http.StatusTeapot, nil))
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 418)
+	c.Assert(response.Body.String(), Equals, "I'm a teapot\n")
+}
+
+func (s *ErrorsSuite) TestHandleErrorWithHandler(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Use(ErrorHandler(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(ErrorStatus(Error(ctx)))
+		rw.Write([]byte("handled: " + Error(ctx).Error()))
+	}))
+	rg.GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		HandleError(ctx, rw, req, NewHTTPError(http.StatusBadRequest, errors.New("bad input")))
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 400)
+	c.Assert(response.Body.String(), Equals, "handled: bad input")
+}
@@ -0,0 +1,169 @@
+package webapp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type hostKey int
+
+const hostMatchKey hostKey = iota
+
+// hostMatch is stored in the request context when a host route matched the request
+type hostMatch struct {
+	pattern string
+	params  RouteParams
+}
+
+type hostRoute struct {
+	pattern string
+	labels  []string
+	router  Router
+}
+
+// Host returns a RouteGroup for the requests to the host, the routes of the group
+// are kept in their own tree. Labels between braces match one label of the host name
+// and are available with Param, requests for other hosts use the routes of the App.
+// Exact host names take precedence over patterns, patterns are tried in order of registration.
+//
+//     api := app.Host("api.example.com")
+//     tenants := app.Host("{tenant}.example.com")
+//     tenants.GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+//         fmt.Fprintf(rw, "welcome %s", webapp.Param(ctx, "tenant"))
+//     })
+func (app *webapp) Host(pattern string) RouteGroup {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if group, ok := app.hostGroups[pattern]; ok {
+		return group
+	}
+
+	labels := strings.Split(pattern, ".")
+	for _, label := range labels {
+		if !validHostLabel(label) {
+			panic("invalid host pattern '" + pattern + "'")
+		}
+	}
+
+	group := &routeGroup{
+		path:       "/",
+		middleware: app.routeGroup.middleware,
+		logger:     app.logger,
+		app:        app,
+		host:       pattern,
+		parent:     app.routeGroup,
+	}
+	app.hostGroups[pattern] = group
+
+	route := &hostRoute{
+		pattern: pattern,
+		labels:  labels,
+	}
+	tree := app.current()
+
+	// exact host names are matched before the patterns
+	var hosts []*hostRoute
+	if !strings.Contains(pattern, "{") {
+		hosts = append([]*hostRoute{route}, tree.hosts...)
+	} else {
+		hosts = append(tree.hosts[:len(tree.hosts):len(tree.hosts)], route)
+	}
+
+	if app.isServing() {
+		if err := app.swap(hosts, app.entries); err != nil {
+			panic(err)
+		}
+		return group
+	}
+
+	route.router = app.newRouter()
+	route.router.SetOptions(app.options)
+	tree.hosts = hosts
+	return group
+}
+
+// routers returns the App router and the routers of the hosts
+func (app *webapp) routers() []Router {
+	tree := app.current()
+	routers := []Router{tree.router}
+	for _, route := range tree.hosts {
+		routers = append(routers, route.router)
+	}
+	return routers
+}
+
+// ServeHTTP dispatches the request to the routes of the matching host
+func (app *webapp) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if !app.isServing() {
+		app.startServing()
+	}
+
+	tree := app.current()
+	host := requestHost(req)
+	for _, route := range tree.hosts {
+		if params, ok := route.match(host); ok {
+			ctx := context.WithValue(req.Context(), hostMatchKey, &hostMatch{
+				pattern: route.pattern,
+				params:  params,
+			})
+			route.router.ServeHTTP(rw, req.WithContext(ctx))
+			return
+		}
+	}
+	tree.router.ServeHTTP(rw, req)
+}
+
+func (route *hostRoute) match(host string) (RouteParams, bool) {
+	labels := strings.Split(host, ".")
+	if len(labels) != len(route.labels) {
+		return nil, false
+	}
+
+	var params RouteParams
+	for i, label := range route.labels {
+		if strings.HasPrefix(label, "{") {
+			params = append(params, RouteParam{Key: label[1 : len(label)-1], Value: labels[i]})
+		} else if label != labels[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// validHostLabel reports if the label is a host name label or a {name} parameter
+func validHostLabel(label string) bool {
+	if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+		label = label[1 : len(label)-1]
+	}
+	return label != "" && !strings.ContainsAny(label, "{}")
+}
+
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// withHostParams prepends the parameters captured from the host to the route parameters
+func withHostParams(req *http.Request, params RouteParams) RouteParams {
+	match, ok := req.Context().Value(hostMatchKey).(*hostMatch)
+	if !ok || len(match.params) == 0 {
+		return params
+	}
+	return append(append(RouteParams{}, match.params...), params...)
+}
+
+// matchedHost returns the pattern of the host route handling the request
+func matchedHost(req *http.Request) string {
+	if match, ok := req.Context().Value(hostMatchKey).(*hostMatch); ok {
+		return match.pattern
+	}
+	return ""
+}
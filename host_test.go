@@ -0,0 +1,66 @@
+package webapp
+
+import (
+	"context"
+	. "gopkg.in/check.v1"
+	"net/http"
+)
+
+type HostSuite struct{}
+
+var _ = Suite(&HostSuite{})
+
+func writeHandler(body string) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(body))
+	}
+}
+
+func (s *HostSuite) TestHostRouting(c *C) {
+	app := New()
+	app.GET("/", writeHandler("main"))
+	app.Host("api.example.com").GET("/", writeHandler("api"))
+	app.Host("API.example.com").GET("/users", writeHandler("users"))
+
+	c.Assert(doTestRequest(app, "GET", "/", map[string]string{"Host": "api.example.com"}).Body.String(), Equals, "api")
+	c.Assert(doTestRequest(app, "GET", "/users", map[string]string{"Host": "Api.Example.com:8080"}).Body.String(), Equals, "users")
+	c.Assert(doTestRequest(app, "GET", "/", map[string]string{"Host": "www.example.com"}).Body.String(), Equals, "main")
+	c.Assert(doTestRequest(app, "GET", "/users", map[string]string{"Host": "www.example.com"}).Code, Equals, 404)
+}
+
+func (s *HostSuite) TestHostPatternParams(c *C) {
+	app := New()
+	app.Host("{tenant}.example.com").Group("/projects").GET("/:project", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(Param(ctx, "tenant") + "/" + Param(ctx, "project")))
+	})
+	app.Host("admin.example.com").GET("/projects/:project", writeHandler("admin"))
+
+	c.Assert(doTestRequest(app, "GET", "/projects/web", map[string]string{"Host": "acme.example.com"}).Body.String(), Equals, "acme/web")
+	c.Assert(doTestRequest(app, "GET", "/projects/web", map[string]string{"Host": "admin.example.com"}).Body.String(), Equals, "admin")
+	c.Assert(doTestRequest(app, "GET", "/projects/web", map[string]string{"Host": "a.b.example.com"}).Code, Equals, 404)
+}
+
+func (s *HostSuite) TestHostUsesAppHandlers(c *C) {
+	app := New()
+	app.NotFound(writeHandler("missing"))
+	app.Host("api.example.com").GET("/", writeHandler("api"))
+	app.MethodNotAllowed(writeHandler("not allowed"))
+
+	c.Assert(doTestRequest(app, "GET", "/unknown", map[string]string{"Host": "api.example.com"}).Body.String(), Equals, "missing")
+	c.Assert(doTestRequest(app, "POST", "/", map[string]string{"Host": "api.example.com"}).Body.String(), Equals, "not allowed")
+}
+
+func (s *HostSuite) TestHostRoutesAreListed(c *C) {
+	app := New()
+	app.Host("{tenant}.example.com").GET("/", writeHandler("tenant"))
+
+	routes := app.Routes()
+	c.Assert(routes, HasLen, 1)
+	c.Assert(routes[0].Host, Equals, "{tenant}.example.com")
+}
+
+func (s *HostSuite) TestInvalidHostPattern(c *C) {
+	for _, pattern := range []string{"{}.example.com", "a{b}.example.com", "example..com", "{tenant.example.com"} {
+		c.Check(func() { New().Host(pattern) }, PanicMatches, "invalid host pattern .*", Commentf(pattern))
+	}
+}
@@ -0,0 +1,591 @@
+package webapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type jwtKey int
+
+const claimsKey jwtKey = iota
+
+// Errors returned when verifying a JWT
+var (
+	ErrJWTMalformed       = errors.New("jwt malformed")
+	ErrJWTAlgorithm       = errors.New("jwt algorithm not allowed")
+	ErrJWTKeyNotFound     = errors.New("jwt signing key not found")
+	ErrJWTSignature       = errors.New("jwt signature invalid")
+	ErrJWTExpired         = errors.New("jwt expired")
+	ErrJWTNotValidYet     = errors.New("jwt not valid yet")
+	ErrJWTInvalidIssuer   = errors.New("jwt issuer invalid")
+	ErrJWTInvalidAudience = errors.New("jwt audience invalid")
+)
+
+// Supported JWT signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// NumericDate is a JWT timestamp in seconds since the epoch
+type NumericDate int64
+
+// UnmarshalJSON accepts integer and fractional timestamps
+func (date *NumericDate) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*date = NumericDate(value)
+	return nil
+}
+
+// Time returns the date as time.Time
+func (date NumericDate) Time() time.Time {
+	return time.Unix(int64(date), 0)
+}
+
+// Audience is the aud claim, a single string or a list of strings
+type Audience []string
+
+// UnmarshalJSON accepts a single string or a list of strings
+func (aud *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*aud = Audience(list)
+	return nil
+}
+
+// Contains reports if the audience contains the value
+func (aud Audience) Contains(value string) bool {
+	for _, v := range aud {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// JWTClaims holds the registered claims of a verified token,
+// all claims including custom ones are available in Raw
+type JWTClaims struct {
+	Issuer    string      `json:"iss"`
+	Subject   string      `json:"sub"`
+	Audience  Audience    `json:"aud"`
+	ExpiresAt NumericDate `json:"exp"`
+	NotBefore NumericDate `json:"nbf"`
+	IssuedAt  NumericDate `json:"iat"`
+	ID        string      `json:"jti"`
+
+	// Scope is the space separated list of scopes (scope claim)
+	Scope string   `json:"scope"`
+	Roles []string `json:"roles"`
+
+	Raw map[string]interface{} `json:"-"`
+}
+
+// JWTKeySet looks up the key to verify a token signature.
+// The key type must match the algorithm, []byte for HS256, *rsa.PublicKey for RS256,
+// *ecdsa.PublicKey for ES256 and ed25519.PublicKey for EdDSA.
+type JWTKeySet interface {
+	Key(ctx context.Context, kid, alg string) (interface{}, error)
+}
+
+// StaticKeySet is a fixed set of keys by key id, the key stored under
+// the empty key id is used for tokens without a kid header
+type StaticKeySet map[string]interface{}
+
+// Key returns the key stored under kid
+func (keys StaticKeySet) Key(_ context.Context, kid, _ string) (interface{}, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrJWTKeyNotFound
+}
+
+// JWTConfig configures the JWT middleware
+type JWTConfig struct {
+	KeySet JWTKeySet
+
+	// Algorithms are the allowed signing algorithms, defaults to all supported algorithms
+	Algorithms []string
+
+	// Issuer is the required iss claim, empty accepts every issuer
+	Issuer string
+
+	// Audience must be present in the aud claim, empty accepts every audience
+	Audience string
+
+	// ClockSkew is the leeway allowed when checking exp and nbf
+	ClockSkew time.Duration
+
+	// Realm used in the WWW-Authenticate challenge
+	Realm string
+}
+
+// JWT is a middleware authenticating requests with a JWT bearer token.
+// The token signature and the exp, nbf, iss and aud claims are verified, the claims are
+// available using Claims(ctx) and the principal using Principal(ctx).
+// Failed requests get a 401 with a Bearer challenge through HandleError.
+//
+//     app.Use(webapp.JWT(webapp.JWTConfig{
+//         KeySet:   webapp.NewJWKSKeySet(webapp.JWKSConfig{URL: "https://id.example.com/.well-known/jwks.json"}),
+//         Issuer:   "https://id.example.com/",
+//         Audience: "api",
+//     }))
+func JWT(config JWTConfig) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			token, ok := bearerToken(req)
+			if !ok {
+				unauthorized(ctx, rw, req, fmt.Sprintf("Bearer realm=%q", config.Realm), ErrMissingCredentials)
+				return
+			}
+
+			claims, err := VerifyJWT(req.Context(), token, config)
+			if err != nil {
+				challenge := fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\", error_description=%q", config.Realm, jwtErrorDescription(err))
+				unauthorized(ctx, rw, req, challenge, err)
+				return
+			}
+
+			ctx = context.WithValue(ctx, claimsKey, claims)
+			ctx = NewContextWithPrincipal(ctx, &Identity{
+				Subject:    claims.Subject,
+				Scheme:     "jwt",
+				Roles:      claims.Roles,
+				Scopes:     strings.Fields(claims.Scope),
+				Attributes: claims.Raw,
+			})
+
+			next(ctx, rw, req)
+		}
+	}
+}
+
+// Claims retrieves the verified JWT claims from the context,
+// nil is returned when the request is not authenticated by the JWT middleware
+func Claims(ctx context.Context) *JWTClaims {
+	if ctx == nil {
+		return nil
+	}
+
+	if claims, ok := ctx.Value(claimsKey).(*JWTClaims); ok {
+		return claims
+	}
+
+	return nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// VerifyJWT verifies the token signature and claims and returns the claims
+func VerifyJWT(ctx context.Context, token string, config JWTConfig) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if !jwtAlgorithmAllowed(config.Algorithms, header.Alg) {
+		return nil, ErrJWTAlgorithm
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if config.KeySet == nil {
+		return nil, ErrJWTKeyNotFound
+	}
+	key, err := config.KeySet.Key(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := &JWTClaims{}
+	if err := decodeJWTSegment(parts[1], claims); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	if err := decodeJWTSegment(parts[1], &claims.Raw); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if err := validateJWTClaims(claims, config, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func jwtAlgorithmAllowed(allowed []string, alg string) bool {
+	if len(allowed) == 0 {
+		allowed = []string{HS256, RS256, ES256, EdDSA}
+	}
+	for _, a := range allowed {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyJWTSignature(alg string, key interface{}, signingInput string, signature []byte) error {
+	hash := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrJWTKeyNotFound
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrJWTSignature
+		}
+
+	case RS256:
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrJWTKeyNotFound
+		}
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature) != nil {
+			return ErrJWTSignature
+		}
+
+	case ES256:
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve != elliptic.P256() {
+			return ErrJWTKeyNotFound
+		}
+		if len(signature) != 64 {
+			return ErrJWTSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, hash[:], r, s) {
+			return ErrJWTSignature
+		}
+
+	case EdDSA:
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrJWTKeyNotFound
+		}
+		if !ed25519.Verify(publicKey, []byte(signingInput), signature) {
+			return ErrJWTSignature
+		}
+
+	default:
+		return ErrJWTAlgorithm
+	}
+	return nil
+}
+
+func validateJWTClaims(claims *JWTClaims, config JWTConfig, now time.Time) error {
+	if claims.ExpiresAt != 0 && now.After(claims.ExpiresAt.Time().Add(config.ClockSkew)) {
+		return ErrJWTExpired
+	}
+	if claims.NotBefore != 0 && now.Before(claims.NotBefore.Time().Add(-config.ClockSkew)) {
+		return ErrJWTNotValidYet
+	}
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return ErrJWTInvalidIssuer
+	}
+	if config.Audience != "" && !claims.Audience.Contains(config.Audience) {
+		return ErrJWTInvalidAudience
+	}
+	return nil
+}
+
+// jwtErrorDescription returns the description of the verification error for the challenge,
+// errors other than the verification errors, e.g. of fetching the keys, are not disclosed
+func jwtErrorDescription(err error) string {
+	for _, known := range []error{
+		ErrJWTMalformed, ErrJWTAlgorithm, ErrJWTKeyNotFound, ErrJWTSignature,
+		ErrJWTExpired, ErrJWTNotValidYet, ErrJWTInvalidIssuer, ErrJWTInvalidAudience,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return "jwt invalid"
+}
+
+// JWKSConfig configures the JWKS key set
+type JWKSConfig struct {
+	// URL of the JWKS document
+	URL string
+
+	// Client used to fetch the document, defaults to http.DefaultClient
+	Client *http.Client
+
+	// CacheDuration is the time the fetched keys are used before fetching them again, defaults to 1 hour
+	CacheDuration time.Duration
+
+	// MinRefreshInterval is the min time between fetches triggered by an unknown key id, defaults to 1 minute
+	MinRefreshInterval time.Duration
+
+	// FetchTimeout is the max duration of fetching the document, defaults to 10 seconds
+	FetchTimeout time.Duration
+}
+
+type jwksKeySet struct {
+	config JWKSConfig
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	failedAt  time.Time
+	err       error
+	fetching  *jwksFetch
+}
+
+// jwksFetch is a fetch in progress, requests wait for it instead of fetching again
+type jwksFetch struct {
+	done chan struct{}
+}
+
+// NewJWKSKeySet creates a key set fetching the keys from a JWKS document.
+// The keys are cached and fetched again when the cache expires or a token
+// uses an unknown key id. Concurrent requests share a single fetch which
+// is not canceled with the requests, when fetching fails the cached keys
+// are used and the document is not fetched again within MinRefreshInterval.
+func NewJWKSKeySet(config JWKSConfig) JWTKeySet {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	if config.CacheDuration <= 0 {
+		config.CacheDuration = time.Hour
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = time.Minute
+	}
+	if config.FetchTimeout <= 0 {
+		config.FetchTimeout = 10 * time.Second
+	}
+	return &jwksKeySet{config: config}
+}
+
+func (set *jwksKeySet) Key(ctx context.Context, kid, _ string) (interface{}, error) {
+	set.mu.Lock()
+	if !set.refresh(kid) {
+		defer set.mu.Unlock()
+		return set.key(kid)
+	}
+
+	fetching := set.fetching
+	if fetching == nil {
+		fetching = &jwksFetch{done: make(chan struct{})}
+		set.fetching = fetching
+		go set.update(fetching)
+	}
+	set.mu.Unlock()
+
+	select {
+	case <-fetching.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return set.key(kid)
+}
+
+// update fetches the keys detached from the requests waiting for them,
+// a request giving up does not fail the fetch for the other requests
+func (set *jwksKeySet) update(fetching *jwksFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), set.config.FetchTimeout)
+	defer cancel()
+
+	keys, err := set.fetch(ctx)
+
+	set.mu.Lock()
+	if err == nil {
+		set.keys, set.fetchedAt, set.failedAt, set.err = keys, time.Now(), time.Time{}, nil
+	} else {
+		set.failedAt, set.err = time.Now(), err
+	}
+	set.fetching = nil
+	set.mu.Unlock()
+	close(fetching.done)
+}
+
+// refresh reports if the keys should be fetched for the key id, after
+// a failed fetch the keys are not fetched again within MinRefreshInterval
+func (set *jwksKeySet) refresh(kid string) bool {
+	if time.Since(set.failedAt) < set.config.MinRefreshInterval {
+		return false
+	}
+
+	since := time.Since(set.fetchedAt)
+	_, known := set.lookup(kid)
+	return set.keys == nil || since > set.config.CacheDuration || (!known && since > set.config.MinRefreshInterval)
+}
+
+// key returns the key by id, the fetch error is returned when no keys were fetched yet
+func (set *jwksKeySet) key(kid string) (interface{}, error) {
+	if key, ok := set.lookup(kid); ok {
+		return key, nil
+	}
+	if set.keys == nil && set.err != nil {
+		return nil, set.err
+	}
+	return nil, ErrJWTKeyNotFound
+}
+
+// lookup finds the key by id, tokens without a key id use the only key in the set
+func (set *jwksKeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key, true
+		}
+	}
+	key, ok := set.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (set *jwksKeySet) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", set.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := set.config.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks fetch failed with status %d", res.StatusCode)
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(document.Keys))
+	for _, k := range document.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := parseJWK(k); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func parseJWK(k jwk) (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	case "oct":
+		return decode(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
@@ -0,0 +1,342 @@
+package webapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type JWTSuite struct {
+	rsaKey     *rsa.PrivateKey
+	ecdsaKey   *ecdsa.PrivateKey
+	ed25519Key ed25519.PrivateKey
+}
+
+var _ = Suite(&JWTSuite{})
+
+func (s *JWTSuite) SetUpSuite(c *C) {
+	var err error
+	s.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	s.ecdsaKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	_, s.ed25519Key, err = ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+}
+
+func signJWT(alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case RS256:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:])
+	case ES256:
+		r, s, _ := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hash[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case EdDSA:
+		signature = ed25519.Sign(key.(ed25519.PrivateKey), []byte(signingInput))
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "user-1",
+		"iss":   "https://id.example.com/",
+		"aud":   []string{"api", "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read write",
+		"roles": []string{"admin"},
+	}
+}
+
+func (s *JWTSuite) TestVerifyAlgorithms(c *C) {
+	secret := []byte("secret")
+	keys := StaticKeySet{
+		"hs":  secret,
+		"rs":  &s.rsaKey.PublicKey,
+		"es":  &s.ecdsaKey.PublicKey,
+		"ed":  s.ed25519Key.Public(),
+		"bad": secret,
+	}
+	config := JWTConfig{KeySet: keys}
+
+	tests := []struct {
+		alg, kid string
+		key      interface{}
+		err      error
+	}{
+		{alg: HS256, kid: "hs", key: secret},
+		{alg: RS256, kid: "rs", key: s.rsaKey},
+		{alg: ES256, kid: "es", key: s.ecdsaKey},
+		{alg: EdDSA, kid: "ed", key: s.ed25519Key},
+		{alg: HS256, kid: "hs", key: []byte("wrong"), err: ErrJWTSignature},
+		{alg: RS256, kid: "bad", key: s.rsaKey, err: ErrJWTKeyNotFound},
+		{alg: HS256, kid: "unknown", key: secret, err: ErrJWTKeyNotFound},
+	}
+
+	for index, test := range tests {
+		token := signJWT(test.alg, test.kid, test.key, validClaims())
+		claims, err := VerifyJWT(context.Background(), token, config)
+
+		c.Check(err, Equals, test.err, Commentf("test %d failed", index))
+		if test.err == nil {
+			c.Check(claims.Subject, Equals, "user-1", Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *JWTSuite) TestVerifyRejectsDisallowedAlgorithm(c *C) {
+	config := JWTConfig{KeySet: StaticKeySet{"": []byte("secret")}, Algorithms: []string{RS256}}
+	token := signJWT(HS256, "", []byte("secret"), validClaims())
+
+	_, err := VerifyJWT(context.Background(), token, config)
+	c.Assert(err, Equals, ErrJWTAlgorithm)
+
+	_, err = VerifyJWT(context.Background(), "not.a-token", config)
+	c.Assert(err, Equals, ErrJWTMalformed)
+}
+
+func (s *JWTSuite) TestVerifyClaims(c *C) {
+	secret := []byte("secret")
+	config := JWTConfig{
+		KeySet:    StaticKeySet{"": secret},
+		Issuer:    "https://id.example.com/",
+		Audience:  "api",
+		ClockSkew: time.Minute,
+	}
+	now := time.Now()
+
+	tests := []struct {
+		claims func(map[string]interface{})
+		err    error
+	}{
+		{claims: func(m map[string]interface{}) {}},
+		{claims: func(m map[string]interface{}) { m["exp"] = now.Add(-30 * time.Second).Unix() }},
+		{claims: func(m map[string]interface{}) { m["exp"] = now.Add(-2 * time.Minute).Unix() }, err: ErrJWTExpired},
+		{claims: func(m map[string]interface{}) { m["nbf"] = now.Add(30 * time.Second).Unix() }},
+		{claims: func(m map[string]interface{}) { m["nbf"] = now.Add(2 * time.Minute).Unix() }, err: ErrJWTNotValidYet},
+		{claims: func(m map[string]interface{}) { m["iss"] = "https://evil.example.com/" }, err: ErrJWTInvalidIssuer},
+		{claims: func(m map[string]interface{}) { m["aud"] = "api" }},
+		{claims: func(m map[string]interface{}) { m["aud"] = "other" }, err: ErrJWTInvalidAudience},
+	}
+
+	for index, test := range tests {
+		claims := validClaims()
+		test.claims(claims)
+		_, err := VerifyJWT(context.Background(), signJWT(HS256, "", secret, claims), config)
+
+		c.Check(err, Equals, test.err, Commentf("test %d failed", index))
+	}
+}
+
+func (s *JWTSuite) TestJWTMiddleware(c *C) {
+	secret := []byte("secret")
+	rg := newRouteGroup(httprouter.New())
+	rg.With(JWT(JWTConfig{KeySet: StaticKeySet{"": secret}, Realm: "api"})).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		claims := Claims(ctx)
+		identity := Principal(ctx)
+		c.Check(claims.Audience, DeepEquals, Audience{"api", "other"})
+		c.Check(identity.Scopes, DeepEquals, []string{"read", "write"})
+		c.Check(identity.Roles, DeepEquals, []string{"admin"})
+		c.Check(identity.Attributes["sub"], Equals, "user-1")
+		rw.Write([]byte(identity.Scheme + ":" + identity.Subject))
+	})
+
+	response := doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Bearer " + signJWT(HS256, "", secret, validClaims())})
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "jwt:user-1")
+
+	response = doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Bearer " + signJWT(HS256, "", []byte("wrong"), validClaims())})
+	c.Assert(response.Code, Equals, 401)
+	c.Assert(response.Header().Get("WWW-Authenticate"), Equals, `Bearer realm="api", error="invalid_token", error_description="jwt signature invalid"`)
+}
+
+func (s *JWTSuite) TestClaimsWithoutMiddleware(c *C) {
+	c.Assert(Claims(nil), IsNil)
+	c.Assert(Claims(context.Background()), IsNil)
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func (s *JWTSuite) jwksServer(fetches *int64, kids ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(fetches, 1)
+		var keys []map[string]string
+		for _, kid := range kids {
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig",
+				"n": encodeBigInt(s.rsaKey.N),
+				"e": encodeBigInt(big.NewInt(int64(s.rsaKey.E))),
+			})
+		}
+		keys = append(keys,
+			map[string]string{
+				"kty": "EC", "kid": "ec", "crv": "P-256",
+				"x": encodeBigInt(s.ecdsaKey.X),
+				"y": encodeBigInt(s.ecdsaKey.Y),
+			},
+			map[string]string{
+				"kty": "OKP", "kid": "ed", "crv": "Ed25519",
+				"x": base64.RawURLEncoding.EncodeToString(s.ed25519Key.Public().(ed25519.PublicKey)),
+			},
+			map[string]string{"kty": "RSA", "kid": "enc", "use": "enc"},
+		)
+		json.NewEncoder(rw).Encode(map[string]interface{}{"keys": keys})
+	}))
+}
+
+func (s *JWTSuite) TestJWKSKeySet(c *C) {
+	var fetches int64
+	server := s.jwksServer(&fetches, "rsa")
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(JWKSConfig{URL: server.URL})
+	config := JWTConfig{KeySet: keySet}
+
+	_, err := VerifyJWT(context.Background(), signJWT(RS256, "rsa", s.rsaKey, validClaims()), config)
+	c.Assert(err, IsNil)
+	_, err = VerifyJWT(context.Background(), signJWT(ES256, "ec", s.ecdsaKey, validClaims()), config)
+	c.Assert(err, IsNil)
+	_, err = VerifyJWT(context.Background(), signJWT(EdDSA, "ed", s.ed25519Key, validClaims()), config)
+	c.Assert(err, IsNil)
+
+	// keys are cached and unknown keys are not fetched within the min refresh interval
+	_, err = VerifyJWT(context.Background(), signJWT(RS256, "enc", s.rsaKey, validClaims()), config)
+	c.Assert(err, Equals, ErrJWTKeyNotFound)
+	c.Assert(atomic.LoadInt64(&fetches), Equals, int64(1))
+}
+
+func (s *JWTSuite) TestJWKSKeySetRefreshesOnUnknownKey(c *C) {
+	var fetches int64
+	server := s.jwksServer(&fetches, "rsa")
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(JWKSConfig{URL: server.URL, MinRefreshInterval: time.Nanosecond})
+	_, err := keySet.Key(context.Background(), "rsa", RS256)
+	c.Assert(err, IsNil)
+
+	_, err = keySet.Key(context.Background(), "unknown", RS256)
+	c.Assert(err, Equals, ErrJWTKeyNotFound)
+	c.Assert(atomic.LoadInt64(&fetches), Equals, int64(2))
+}
+
+func (s *JWTSuite) TestJWKSKeySetFetchError(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(JWKSConfig{URL: server.URL})
+	_, err := keySet.Key(context.Background(), "rsa", RS256)
+
+	c.Assert(err, ErrorMatches, "jwks fetch failed with status 500")
+}
+
+func (s *JWTSuite) TestJWKSKeySetFetchesOnce(c *C) {
+	var fetches int64
+	release := make(chan struct{})
+	keys := s.jwksServer(&fetches, "rsa")
+	defer keys.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+		keys.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(JWKSConfig{URL: server.URL})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keySet.Key(context.Background(), "rsa", RS256)
+			c.Check(err, IsNil)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	c.Assert(atomic.LoadInt64(&fetches), Equals, int64(1))
+}
+
+func (s *JWTSuite) TestJWKSKeySetFetchOutlivesCanceledRequest(c *C) {
+	var fetches int64
+	keys := s.jwksServer(&fetches, "rsa")
+	defer keys.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		keys.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(JWKSConfig{URL: server.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := keySet.Key(ctx, "rsa", RS256)
+	c.Assert(err, Equals, context.DeadlineExceeded)
+
+	key, err := keySet.Key(context.Background(), "rsa", RS256)
+	c.Assert(err, IsNil)
+	c.Assert(key, NotNil)
+	c.Assert(atomic.LoadInt64(&fetches), Equals, int64(1))
+}
+
+func (s *JWTSuite) TestJWKSKeySetBacksOffAfterFailure(c *C) {
+	var fetches int64
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&fetches, 1)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(JWKSConfig{URL: server.URL})
+	for i := 0; i < 3; i++ {
+		_, err := keySet.Key(context.Background(), "rsa", RS256)
+		c.Assert(err, ErrorMatches, "jwks fetch failed with status 500")
+	}
+	c.Assert(atomic.LoadInt64(&fetches), Equals, int64(1))
+}
+
+func (s *JWTSuite) TestJWTHidesInternalErrors(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	rg := newRouteGroup(httprouter.New())
+	rg.With(JWT(JWTConfig{Realm: "api", KeySet: NewJWKSKeySet(JWKSConfig{URL: server.URL})})).GET("/", principalHandler)
+
+	response := doTestRequest(rg, "GET", "/", map[string]string{"Authorization": "Bearer " + signJWT(RS256, "rsa", s.rsaKey, validClaims())})
+	c.Assert(response.Code, Equals, 401)
+	c.Assert(response.Header().Get("WWW-Authenticate"), Equals, `Bearer realm="api", error="invalid_token", error_description="jwt invalid"`)
+}
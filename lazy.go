@@ -0,0 +1,83 @@
+package webapp
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// NewLazy creates an App using the routers created by newRouter which registers its routes
+// when Build is called or the first request is served. The routes get the middleware their
+// group and its parents have at that moment, middleware added with Use applies to all
+// routes regardless of the order of registration.
+//
+// Invalid or conflicting routes are reported by Build, when not called before the
+// first request panics with the error. Use after the routes are build rebuilds the
+// routes, using it while serving is logged as warning.
+//
+//     app := webapp.NewLazy(webapp.NewHTTPRouter)
+//     app.GET("/", index)
+//     app.Use(webapp.LogRequest(logger))
+//     if err := app.Build(); err != nil {
+//         log.Fatal(err)
+//     }
+func NewLazy(newRouter func() Router) App {
+	app := NewWithRouter(newRouter).(*webapp)
+	app.lazy = true
+	return app
+}
+
+// Build registers the routes of a lazy App, for other Apps the routes are registered immediately
+func (app *webapp) Build() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	return app.build()
+}
+
+func (app *webapp) build() error {
+	if !app.lazy || app.built {
+		return nil
+	}
+
+	app.built = true
+	if err := app.swap(app.current().hosts, app.entries); err != nil {
+		app.built = false
+		return err
+	}
+	return nil
+}
+
+// startServing builds the routes before the first request is handled, routes
+// registered after this are added by replacing the route tree
+func (app *webapp) startServing() {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if err := app.build(); err != nil {
+		panic(err)
+	}
+	atomic.StoreInt32(&app.serving, 1)
+}
+
+// use adds the middleware to a group of a lazy App, routes already build are rebuild
+func (app *webapp) use(group *routeGroup, middleware []Middleware) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	group.middleware = group.middleware.Append(middleware...)
+	group.local = group.local.Append(middleware...)
+	if !app.built {
+		return
+	}
+
+	if app.isServing() {
+		logger := app.logger
+		if logger == nil {
+			logger = log.Default()
+		}
+		logger.Printf("webapp: Use called on group %s while serving, rebuilding the routes", group.path)
+	}
+	if err := app.swap(app.current().hosts, app.entries); err != nil {
+		panic(err)
+	}
+}
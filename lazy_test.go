@@ -0,0 +1,96 @@
+package webapp
+
+import (
+	"bytes"
+	. "gopkg.in/check.v1"
+	"log"
+	"net/http"
+)
+
+type LazySuite struct{}
+
+var _ = Suite(&LazySuite{})
+
+func (s *LazySuite) TestUseAppliesToEarlierRoutes(c *C) {
+	app := NewLazy(NewHTTPRouter)
+	api := app.Group("/api")
+	api.GET("/users", writeHandler("users"))
+	app.GET("/", writeHandler("index"))
+	app.Use(headerMiddleware("app"))
+	api.Use(headerMiddleware("api"))
+
+	c.Assert(app.Build(), IsNil)
+
+	rw := doTestRequest(app, "GET", "/api/users")
+	c.Assert(rw.Body.String(), Equals, "users")
+	c.Assert(rw.Header()["X-Middleware"], DeepEquals, []string{"app", "api"})
+	c.Assert(doTestRequest(app, "GET", "/").Header()["X-Middleware"], DeepEquals, []string{"app"})
+	c.Assert(doTestRequest(app, "GET", "/missing").Header()["X-Middleware"], DeepEquals, []string{"app"})
+
+	routes := app.Routes()
+	c.Assert(routes, HasLen, 2)
+	c.Assert(routes[0].Middlewares, Equals, 2)
+}
+
+func (s *LazySuite) TestBuildOnFirstRequest(c *C) {
+	app := NewLazy(NewTreeRouter)
+	app.GET("/", writeHandler("index"))
+	c.Assert(app.Routes(), HasLen, 0)
+
+	app.Use(headerMiddleware("app"))
+	rw := doTestRequest(app, "GET", "/")
+	c.Assert(rw.Body.String(), Equals, "index")
+	c.Assert(rw.Header().Get("X-Middleware"), Equals, "app")
+	c.Assert(app.Routes(), HasLen, 1)
+}
+
+func (s *LazySuite) TestHostGroupsInheritUse(c *C) {
+	app := NewLazy(NewHTTPRouter)
+	app.Host("api.example.com").GET("/", writeHandler("api"))
+	app.Use(headerMiddleware("app"))
+
+	rw := doTestRequest(app, "GET", "/", map[string]string{"Host": "api.example.com"})
+	c.Assert(rw.Body.String(), Equals, "api")
+	c.Assert(rw.Header().Get("X-Middleware"), Equals, "app")
+}
+
+func (s *LazySuite) TestBuildReportsErrors(c *C) {
+	app := NewLazy(NewHTTPRouter)
+	app.GET("/users/:id", writeHandler("user"))
+	app.GET("/users/:id", writeHandler("duplicate"))
+
+	c.Assert(app.Build(), ErrorMatches, "route GET /users/:id: .*")
+	c.Assert(func() { doTestRequest(app, "GET", "/users/1") }, PanicMatches, "route GET /users/:id: .*")
+}
+
+func (s *LazySuite) TestUseWhileServing(c *C) {
+	var buf bytes.Buffer
+	app := NewLazy(NewHTTPRouter)
+	app.Logger(log.New(&buf, "", 0))
+	app.GET("/", writeHandler("index"))
+	c.Assert(doTestRequest(app, "GET", "/").Header().Get("X-Middleware"), Equals, "")
+
+	app.Use(headerMiddleware("late"))
+	c.Assert(doTestRequest(app, "GET", "/").Header().Get("X-Middleware"), Equals, "late")
+	c.Assert(buf.String(), Matches, "(?s).*Use called on group / while serving.*")
+}
+
+func (s *LazySuite) TestRegisterAfterBuild(c *C) {
+	app := NewLazy(NewHTTPRouter)
+	app.Use(headerMiddleware("app"))
+	c.Assert(app.Build(), IsNil)
+
+	app.GET("/", writeHandler("index"))
+	c.Assert(app.Register("GET", "/", writeHandler("duplicate")), NotNil)
+
+	rw := doTestRequest(app, "GET", "/")
+	c.Assert(rw.Body.String(), Equals, "index")
+	c.Assert(rw.Header().Get("X-Middleware"), Equals, "app")
+}
+
+func (s *LazySuite) TestBuildWithoutLazy(c *C) {
+	app := New()
+	app.GET("/", writeHandler("index"))
+	c.Assert(app.Build(), IsNil)
+	c.Assert(doTestRequest(app, "GET", "/").Code, Equals, http.StatusOK)
+}
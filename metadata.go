@@ -0,0 +1,32 @@
+package webapp
+
+import (
+	"context"
+)
+
+// Meta creates a new Group with the same path attaching the value under the key to its routes. The
+// metadata is listed by Routes and available to all middleware of the route with RouteMeta.
+// Metadata set closer to the route takes precedence over metadata of the group.
+//
+//     admin := app.Group("/admin").Meta("ratelimit", "strict")
+//     admin.Meta("scopes", []string{"users:write"}).POST("/users", createUser)
+func (group *routeGroup) Meta(key string, value interface{}) RouteGroup {
+	metadata := make(map[string]interface{}, len(group.metadata)+1)
+	for k, v := range group.metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+
+	described := group.With().(*routeGroup)
+	described.metadata = metadata
+	return described
+}
+
+// RouteMeta returns the metadata under the key of the route handling the request
+func RouteMeta(ctx context.Context, key string) interface{} {
+	route := currentRoute(ctx)
+	if route == nil {
+		return nil
+	}
+	return route.Metadata[key]
+}
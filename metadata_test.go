@@ -0,0 +1,63 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+)
+
+type MetadataSuite struct{}
+
+var _ = Suite(&MetadataSuite{})
+
+func metaHeaderMiddleware(key string) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			if value, ok := RouteMeta(ctx, key).(string); ok {
+				rw.Header().Set("X-"+key, value)
+			}
+			next(ctx, rw, req)
+		}
+	}
+}
+
+func (s *MetadataSuite) TestMiddlewareReadsMetadata(c *C) {
+	app := New()
+	app.Use(metaHeaderMiddleware("Class"))
+	api := app.Group("/api").Meta("Class", "default")
+	api.GET("/users", writeHandler("users"))
+	api.Meta("Class", "strict").POST("/users", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(RouteMeta(ctx, "Class").(string)))
+	})
+	app.GET("/", writeHandler("index"))
+
+	c.Assert(doTestRequest(app, "GET", "/api/users").Header().Get("X-Class"), Equals, "default")
+
+	rw := doTestRequest(app, "POST", "/api/users")
+	c.Assert(rw.Header().Get("X-Class"), Equals, "strict")
+	c.Assert(rw.Body.String(), Equals, "strict")
+
+	c.Assert(doTestRequest(app, "GET", "/").Header().Get("X-Class"), Equals, "")
+}
+
+func (s *MetadataSuite) TestRoutesListMetadata(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Meta("summary", "List users").Meta("tags", []string{"users"}).GET("/users", writeHandler("users"))
+	rg.GET("/", writeHandler("index"))
+
+	routes := rg.Routes()
+	c.Assert(routes[0].Metadata, DeepEquals, map[string]interface{}{
+		"summary": "List users",
+		"tags":    []string{"users"},
+	})
+	c.Assert(routes[1].Metadata, IsNil)
+
+	routes[0].Metadata["summary"] = "changed"
+	c.Assert(rg.Routes()[0].Metadata["summary"], Equals, "List users")
+}
+
+func (s *MetadataSuite) TestRouteMetaWithoutRoute(c *C) {
+	c.Assert(RouteMeta(nil, "summary"), IsNil)
+	c.Assert(RouteMeta(context.Background(), "summary"), IsNil)
+}
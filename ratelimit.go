@@ -0,0 +1,343 @@
+package webapp
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm selects the algorithm used to limit the requests
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts up to Burst requests and refills Limit tokens every Window
+	TokenBucket RateLimitAlgorithm = iota
+
+	// SlidingWindow allows Limit requests in every Window, the previous window
+	// is weighted to smooth out the boundary between two windows
+	SlidingWindow
+)
+
+// RateLimitState holds the state of one rate limit key.
+// Stores only need to keep the state, the algorithms update it.
+type RateLimitState struct {
+	// Tokens left in the bucket (token bucket)
+	Tokens float64
+
+	// Count and PrevCount are the number of requests in the current and previous window (sliding window)
+	Count     int64
+	PrevCount int64
+
+	// Timestamp is the last refill of the bucket or the start of the current window
+	Timestamp time.Time
+}
+
+// RateLimitStore stores the rate limit state per key.
+// Update must apply fn atomically to the state stored under key, a zero state is
+// passed for unknown or expired keys. The state may be dropped after ttl.
+type RateLimitStore interface {
+	Update(key string, ttl time.Duration, fn func(state *RateLimitState)) error
+}
+
+// RateLimitKeyFunc returns the key to limit the request on
+type RateLimitKeyFunc func(ctx context.Context, req *http.Request) string
+
+// RateLimitConfig configures the RateLimit middleware
+type RateLimitConfig struct {
+	// Limit is the number of requests allowed every Window
+	Limit  int
+	Window time.Duration
+
+	// Burst is the capacity of the token bucket, defaults to Limit
+	Burst int
+
+	Algorithm RateLimitAlgorithm
+
+	// Key selects the client the limit applies to, defaults to KeyByIP()
+	Key RateLimitKeyFunc
+
+	// Store holds the state, defaults to a new in memory store
+	Store RateLimitStore
+
+	// LimitHandler is called when the limit is exceeded, the
+	// Retry-After header is already set. Defaults to a 429 response.
+	LimitHandler ContextHandler
+}
+
+type rateLimitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// RateLimit is a middleware that limits the number of requests per client.
+// The RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers are set on every response,
+// when the limit is exceeded a Retry-After header is added and the LimitHandler is called.
+// When the store fails the request is allowed.
+//
+//     app.Group("/api", webapp.RateLimit(webapp.RateLimitConfig{
+//         Limit:  100,
+//         Window: time.Minute,
+//     }))
+func RateLimit(config RateLimitConfig) Middleware {
+	if config.Limit <= 0 || config.Window <= 0 {
+		panic("rate limit requires a positive limit and window")
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.Key == nil {
+		config.Key = KeyByIP()
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore(0)
+	}
+
+	limitHandler := config.LimitHandler
+	if limitHandler == nil {
+		limitHandler = defaultRateLimitHandler
+	}
+
+	ttl := 2 * config.Window
+	if config.Algorithm == TokenBucket {
+		ttl = time.Duration(float64(config.Window) * float64(config.Burst) / float64(config.Limit))
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			now := time.Now()
+
+			var result rateLimitResult
+			err := config.Store.Update(config.Key(ctx, req), ttl, func(state *RateLimitState) {
+				result = takeRateLimit(config, state, now)
+			})
+			if err != nil {
+				next(ctx, rw, req)
+				return
+			}
+
+			header := rw.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+
+			if !result.allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.retryAfter)))
+				limitHandler(ctx, rw, req)
+				return
+			}
+
+			next(ctx, rw, req)
+		}
+	}
+}
+
+func takeRateLimit(config RateLimitConfig, state *RateLimitState, now time.Time) rateLimitResult {
+	if config.Algorithm == SlidingWindow {
+		return takeSlidingWindow(config, state, now)
+	}
+	return takeTokenBucket(config, state, now)
+}
+
+func takeTokenBucket(config RateLimitConfig, state *RateLimitState, now time.Time) rateLimitResult {
+	capacity := float64(config.Burst)
+	perSecond := float64(config.Limit) / config.Window.Seconds()
+
+	if state.Timestamp.IsZero() {
+		state.Tokens = capacity
+	} else if elapsed := now.Sub(state.Timestamp).Seconds(); elapsed > 0 {
+		state.Tokens = math.Min(capacity, state.Tokens+elapsed*perSecond)
+	}
+	state.Timestamp = now
+
+	result := rateLimitResult{limit: config.Burst}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.allowed = true
+	} else {
+		result.retryAfter = secondsToDuration((1 - state.Tokens) / perSecond)
+	}
+	result.remaining = int(state.Tokens)
+	result.reset = secondsToDuration((capacity - state.Tokens) / perSecond)
+	return result
+}
+
+func takeSlidingWindow(config RateLimitConfig, state *RateLimitState, now time.Time) rateLimitResult {
+	windowStart := now.Truncate(config.Window)
+	if !state.Timestamp.Equal(windowStart) {
+		if state.Timestamp.Equal(windowStart.Add(-config.Window)) {
+			state.PrevCount = state.Count
+		} else {
+			state.PrevCount = 0
+		}
+		state.Count = 0
+		state.Timestamp = windowStart
+	}
+
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(config.Window)
+	estimated := float64(state.PrevCount)*weight + float64(state.Count)
+
+	result := rateLimitResult{
+		limit: config.Limit,
+		reset: config.Window - elapsed,
+	}
+	if estimated+1 <= float64(config.Limit) {
+		state.Count++
+		estimated++
+		result.allowed = true
+	} else {
+		result.retryAfter = result.reset
+	}
+	result.remaining = config.Limit - int(math.Ceil(estimated))
+	if result.remaining < 0 {
+		result.remaining = 0
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func defaultRateLimitHandler(_ context.Context, rw http.ResponseWriter, _ *http.Request) {
+	http.Error(rw,
+		http.StatusText(http.StatusTooManyRequests),
+		http.StatusTooManyRequests,
+	)
+}
+
+// KeyByIP limits on the ip address of the client
+func KeyByIP() RateLimitKeyFunc {
+	return func(_ context.Context, req *http.Request) string {
+		return clientIP(req)
+	}
+}
+
+// KeyByHeader limits on the value of the request header, e.g. an api key
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(_ context.Context, req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// KeyByRoute limits on the request method and the pattern of the route handling the request,
+// all paths matching a route share the limit, e.g. GET /users/:id for /users/1 and /users/2.
+// The pattern of a route on a host is prefixed with the host pattern. Requests not handled by
+// a route, e.g. by the NotFound handler, share the limit of their method.
+func KeyByRoute() RateLimitKeyFunc {
+	return func(ctx context.Context, req *http.Request) string {
+		route := currentRoute(ctx)
+		if route == nil {
+			return req.Method + " "
+		}
+		return req.Method + " " + route.Host + route.Path
+	}
+}
+
+// KeyByAll combines the keys, e.g. KeyByAll(KeyByIP(), KeyByRoute()) limits every client per route
+func KeyByAll(keys ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(ctx context.Context, req *http.Request) string {
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = key(ctx, req)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+const (
+	defaultRateLimitShards = 32
+
+	// number of updates on a shard before the expired entries are evicted
+	rateLimitEvictInterval = 1024
+)
+
+type memoryRateLimitStore struct {
+	shards []*rateLimitShard
+}
+
+type rateLimitShard struct {
+	sync.Mutex
+	entries map[string]*rateLimitEntry
+	updates int
+}
+
+type rateLimitEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates an in memory store split in the given number of shards,
+// each shard has its own lock. Expired entries are evicted while updating.
+// When shards is 0 a default of 32 shards is used.
+func NewMemoryRateLimitStore(shards int) RateLimitStore {
+	if shards <= 0 {
+		shards = defaultRateLimitShards
+	}
+
+	store := &memoryRateLimitStore{
+		shards: make([]*rateLimitShard, shards),
+	}
+	for i := range store.shards {
+		store.shards[i] = &rateLimitShard{
+			entries: make(map[string]*rateLimitEntry),
+		}
+	}
+	return store
+}
+
+func (store *memoryRateLimitStore) Update(key string, ttl time.Duration, fn func(state *RateLimitState)) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	shard := store.shards[hash.Sum32()%uint32(len(store.shards))]
+
+	now := time.Now()
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	shard.updates++
+	if shard.updates >= rateLimitEvictInterval {
+		shard.updates = 0
+		shard.evict(now)
+	}
+
+	entry, ok := shard.entries[key]
+	if !ok || now.After(entry.expires) {
+		entry = &rateLimitEntry{}
+		shard.entries[key] = entry
+	}
+
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	return nil
+}
+
+func (shard *rateLimitShard) evict(now time.Time) {
+	for key, entry := range shard.entries {
+		if now.After(entry.expires) {
+			delete(shard.entries, key)
+		}
+	}
+}
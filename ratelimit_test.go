@@ -0,0 +1,160 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type RateLimitSuite struct{}
+
+var _ = Suite(&RateLimitSuite{})
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Update(string, time.Duration, func(*RateLimitState)) error {
+	return errors.New("store down")
+}
+
+func (s *RateLimitSuite) TestTokenBucket(c *C) {
+	config := RateLimitConfig{Limit: 2, Burst: 2, Window: time.Second}
+	state := &RateLimitState{}
+	now := time.Now()
+
+	c.Assert(takeTokenBucket(config, state, now).allowed, Equals, true)
+	c.Assert(takeTokenBucket(config, state, now).allowed, Equals, true)
+
+	result := takeTokenBucket(config, state, now)
+	c.Assert(result.allowed, Equals, false)
+	c.Assert(result.remaining, Equals, 0)
+	c.Assert(result.retryAfter, Equals, 500*time.Millisecond)
+
+	c.Assert(takeTokenBucket(config, state, now.Add(500*time.Millisecond)).allowed, Equals, true)
+}
+
+func (s *RateLimitSuite) TestSlidingWindow(c *C) {
+	config := RateLimitConfig{Limit: 2, Window: time.Minute}
+	state := &RateLimitState{}
+	start := time.Now().Truncate(time.Minute)
+
+	c.Assert(takeSlidingWindow(config, state, start).allowed, Equals, true)
+	c.Assert(takeSlidingWindow(config, state, start.Add(time.Second)).allowed, Equals, true)
+
+	result := takeSlidingWindow(config, state, start.Add(30*time.Second))
+	c.Assert(result.allowed, Equals, false)
+	c.Assert(result.retryAfter, Equals, 30*time.Second)
+
+	// half way the next window the previous window counts for one request
+	result = takeSlidingWindow(config, state, start.Add(90*time.Second))
+	c.Assert(result.allowed, Equals, true)
+	c.Assert(result.remaining, Equals, 0)
+	c.Assert(takeSlidingWindow(config, state, start.Add(91*time.Second)).allowed, Equals, false)
+
+	// an older window does not count
+	c.Assert(takeSlidingWindow(config, state, start.Add(5*time.Minute)).allowed, Equals, true)
+	c.Assert(state.PrevCount, Equals, int64(0))
+}
+
+func (s *RateLimitSuite) TestRateLimitMiddleware(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(RateLimit(RateLimitConfig{Limit: 1, Window: time.Minute})).GET("/", finalHandler)
+
+	response := doTestRequest(rg, "GET", "/")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Header().Get("RateLimit-Limit"), Equals, "1")
+	c.Assert(response.Header().Get("RateLimit-Remaining"), Equals, "0")
+	c.Assert(response.Header().Get("RateLimit-Reset"), Equals, "60")
+
+	response = doTestRequest(rg, "GET", "/")
+	c.Assert(response.Code, Equals, 429)
+	c.Assert(response.Header().Get("Retry-After"), Equals, "60")
+}
+
+func (s *RateLimitSuite) TestRateLimitKeyByHeader(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(RateLimit(RateLimitConfig{
+		Limit:     1,
+		Window:    time.Minute,
+		Algorithm: SlidingWindow,
+		Key:       KeyByHeader("X-Api-Key"),
+	})).GET("/", finalHandler)
+
+	request := func(key string) int {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Api-Key", key)
+		rg.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	c.Assert(request("a"), Equals, 200)
+	c.Assert(request("b"), Equals, 200)
+	c.Assert(request("a"), Equals, 429)
+}
+
+func (s *RateLimitSuite) TestRateLimitFailsOpen(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(RateLimit(RateLimitConfig{
+		Limit:  1,
+		Window: time.Minute,
+		Store:  failingRateLimitStore{},
+	})).GET("/", finalHandler)
+
+	c.Assert(doTestRequest(rg, "GET", "/").Code, Equals, 200)
+	c.Assert(doTestRequest(rg, "GET", "/").Code, Equals, 200)
+}
+
+func (s *RateLimitSuite) TestRateLimitPanicsOnInvalidConfig(c *C) {
+	c.Assert(func() { RateLimit(RateLimitConfig{}) }, PanicMatches, "rate limit requires a positive limit and window")
+}
+
+func (s *RateLimitSuite) TestKeyFuncs(c *C) {
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	ctx := context.WithValue(context.Background(), routeInfoKey, &RouteInfo{Method: "GET", Path: "/users/:id"})
+
+	c.Assert(KeyByIP()(nil, req), Equals, "10.0.0.1")
+	c.Assert(KeyByRoute()(ctx, req), Equals, "GET /users/:id")
+	c.Assert(KeyByRoute()(nil, req), Equals, "GET ")
+	c.Assert(KeyByAll(KeyByIP(), KeyByRoute())(ctx, req), Equals, "10.0.0.1|GET /users/:id")
+}
+
+func (s *RateLimitSuite) TestKeyByRouteSharesLimitOfRoute(c *C) {
+	app := New()
+	app.Use(RateLimit(RateLimitConfig{Limit: 1, Window: time.Minute, Key: KeyByRoute()}))
+	app.GET("/users/:id", finalHandler)
+	app.GET("/orders/:id", finalHandler)
+	app.Host("api.example.com").GET("/users/:id", finalHandler)
+
+	c.Assert(doTestRequest(app, "GET", "/users/1").Code, Equals, 200)
+	c.Assert(doTestRequest(app, "GET", "/users/2").Code, Equals, 429)
+	c.Assert(doTestRequest(app, "GET", "/orders/1").Code, Equals, 200)
+	c.Assert(doTestRequest(app, "GET", "/users/1", map[string]string{"Host": "api.example.com"}).Code, Equals, 200)
+}
+
+func (s *RateLimitSuite) TestMemoryStoreEvictsExpiredEntries(c *C) {
+	store := NewMemoryRateLimitStore(1).(*memoryRateLimitStore)
+
+	store.Update("expired", -time.Second, func(state *RateLimitState) { state.Count = 1 })
+	for i := 0; i < rateLimitEvictInterval; i++ {
+		store.Update("active", time.Minute, func(state *RateLimitState) {})
+	}
+
+	c.Assert(store.shards[0].entries, HasLen, 1)
+}
+
+func (s *RateLimitSuite) TestMemoryStoreResetsExpiredState(c *C) {
+	store := NewMemoryRateLimitStore(4)
+
+	store.Update("key", -time.Second, func(state *RateLimitState) { state.Count = 5 })
+
+	var count int64
+	store.Update("key", time.Minute, func(state *RateLimitState) { count = state.Count })
+
+	c.Assert(count, Equals, int64(0))
+}
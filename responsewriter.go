@@ -3,6 +3,7 @@ package webapp
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 )
@@ -10,24 +11,54 @@ import (
 type ResponseWriter interface {
 	http.ResponseWriter
 	http.Flusher
+
+	// Status returns the final status written, 1xx informational responses are not tracked
 	Status() int
+
+	// Written reports if the status and headers are written, alias of HeaderWritten
 	Written() bool
+
+	// HeaderWritten reports if the status and headers are written
+	HeaderWritten() bool
+
+	// BodyWritten reports if the body is written
+	BodyWritten() bool
+
 	Size() int
+
+	// Before registers a function called right before the status is written,
+	// the functions are called once in reverse order of registration
+	Before(func(ResponseWriter))
 }
 
 // NewResponseWriter creates a ResponseWriter that wraps an http.ResponseWriter
 func newResponseWriter(rw http.ResponseWriter) ResponseWriter {
-	return &responseWriter{rw, 0, 0}
+	return &responseWriter{ResponseWriter: rw}
 }
 
 type responseWriter struct {
 	http.ResponseWriter
-	status int
-	size   int
+	status      int
+	size        int
+	bodyWritten bool
+	beforeFuncs []func(ResponseWriter)
 }
 
+// WriteHeader writes the status, calls after the status is written are silently ignored.
+// 1xx informational responses, like 103 Early Hints, are sent directly and
+// do not count as the written status.
 func (rw *responseWriter) WriteHeader(status int) {
+	if rw.Written() {
+		return
+	}
+
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		rw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
 	rw.status = status
+	rw.callBefore()
 	rw.ResponseWriter.WriteHeader(status)
 }
 
@@ -36,6 +67,7 @@ func (rw *responseWriter) Write(data []byte) (int, error) {
 		// If the status is not set we make sure the response is a 200
 		rw.WriteHeader(http.StatusOK)
 	}
+	rw.bodyWritten = true
 	size, err := rw.ResponseWriter.Write(data)
 	rw.size += size
 	return size, err
@@ -53,6 +85,26 @@ func (rw *responseWriter) Written() bool {
 	return rw.status != 0
 }
 
+func (rw *responseWriter) HeaderWritten() bool {
+	return rw.status != 0
+}
+
+func (rw *responseWriter) BodyWritten() bool {
+	return rw.bodyWritten
+}
+
+func (rw *responseWriter) Before(before func(ResponseWriter)) {
+	rw.beforeFuncs = append(rw.beforeFuncs, before)
+}
+
+func (rw *responseWriter) callBefore() {
+	beforeFuncs := rw.beforeFuncs
+	rw.beforeFuncs = nil
+	for i := len(beforeFuncs) - 1; i >= 0; i-- {
+		beforeFuncs[i](rw)
+	}
+}
+
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
 	if !ok {
@@ -61,13 +113,71 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return hijacker.Hijack()
 }
 
+// CloseNotify returns the close notification channel of the underlying writer,
+// when not supported a channel is returned that never receives a value
+//
+// Deprecated: use the context of the request instead
 func (rw *responseWriter) CloseNotify() <-chan bool {
-	return rw.ResponseWriter.(http.CloseNotifier).CloseNotify()
+	if notifier, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
 }
 
 func (rw *responseWriter) Flush() {
-	flusher, ok := rw.ResponseWriter.(http.Flusher)
-	if ok {
+	rw.FlushError()
+}
+
+// FlushError flushes the buffered data to the client, http.ErrNotSupported
+// is returned when the underlying writer can not flush
+func (rw *responseWriter) FlushError() error {
+	if !rw.Written() {
+		// flushing sends the headers with a 200
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	switch flusher := rw.ResponseWriter.(type) {
+	case interface{ FlushError() error }:
+		return flusher.FlushError()
+	case http.Flusher:
 		flusher.Flush()
+		return nil
+	}
+	return http.ErrNotSupported
+}
+
+// Push initiates a HTTP/2 server push, http.ErrNotSupported is returned
+// when the underlying writer does not support push
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom copies the reader to the response, the ReadFrom of the underlying
+// writer is used when available which allows the use of sendfile
+func (rw *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !rw.Written() {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if readerFrom, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		rw.bodyWritten = true
+		n, err := readerFrom.ReadFrom(r)
+		rw.size += int(n)
+		return n, err
 	}
+	return io.Copy(writerOnly{rw}, r)
+}
+
+// Unwrap returns the underlying writer, used by http.ResponseController
+// to reach the deadline and full duplex methods of the connection
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// writerOnly hides the ReadFrom method to prevent io.Copy from calling it recursively
+type writerOnly struct {
+	io.Writer
 }
@@ -1,8 +1,17 @@
 package webapp
 
 import (
+	"bytes"
+	"context"
+	"github.com/julienschmidt/httprouter"
 	. "gopkg.in/check.v1"
+	"io"
+	"log"
+	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ResponseWriterSuite struct{}
@@ -28,3 +37,221 @@ func (s *ResponseWriterSuite) TestResponseWriterDataWritten(c *C) {
 	c.Assert(rw.Size(), Equals, 4)
 	c.Assert(rw.Status(), Equals, 200)
 }
+
+func (s *ResponseWriterSuite) TestResponseWriterBefore(c *C) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	var calls []string
+	rw.Before(func(rw ResponseWriter) {
+		calls = append(calls, "first")
+		rw.Header().Set("X-Status", strconv.Itoa(rw.Status()))
+	})
+	rw.Before(func(rw ResponseWriter) {
+		calls = append(calls, "second")
+	})
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte("1234"))
+
+	c.Assert(calls, DeepEquals, []string{"second", "first"})
+	c.Assert(rec.Header().Get("X-Status"), Equals, "201")
+	c.Assert(rec.Code, Equals, 201)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterBeforeOnWrite(c *C) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	called := 0
+	rw.Before(func(rw ResponseWriter) {
+		called++
+		rw.Header().Set("X-Before", "yes")
+	})
+
+	rw.Write([]byte("1234"))
+	rw.Write([]byte("5678"))
+
+	c.Assert(called, Equals, 1)
+	c.Assert(rec.Header().Get("X-Before"), Equals, "yes")
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterBeforeOnFlush(c *C) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	called := false
+	rw.Before(func(rw ResponseWriter) {
+		called = true
+	})
+
+	rw.Flush()
+
+	c.Assert(called, Equals, true)
+	c.Assert(rw.Status(), Equals, 200)
+	c.Assert(rec.Flushed, Equals, true)
+}
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (rec *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	rec.pushed = append(rec.pushed, target)
+	return nil
+}
+
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (rec *readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	rec.readFrom = true
+	return io.Copy(rec.ResponseRecorder, r)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterPush(c *C) {
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rw := newResponseWriter(rec)
+
+	c.Assert(rw.(http.Pusher).Push("/app.js", nil), IsNil)
+	c.Assert(rec.pushed, DeepEquals, []string{"/app.js"})
+
+	rw = newResponseWriter(httptest.NewRecorder())
+	c.Assert(rw.(http.Pusher).Push("/app.js", nil), Equals, http.ErrNotSupported)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterReadFrom(c *C) {
+	rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rw := newResponseWriter(rec)
+
+	n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("1234"))
+
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(4))
+	c.Assert(rec.readFrom, Equals, true)
+	c.Assert(rw.Size(), Equals, 4)
+	c.Assert(rw.Status(), Equals, 200)
+	c.Assert(rec.Body.String(), Equals, "1234")
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterReadFromFallback(c *C) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("1234"))
+
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(4))
+	c.Assert(rw.Size(), Equals, 4)
+	c.Assert(rec.Body.String(), Equals, "1234")
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterCloseNotifyUnsupported(c *C) {
+	rw := newResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()})
+
+	c.Assert(rw.(http.CloseNotifier).CloseNotify(), NotNil)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterFlushUnsupported(c *C) {
+	rw := newResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()})
+
+	err := http.NewResponseController(rw).Flush()
+
+	c.Assert(err, Equals, http.ErrNotSupported)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterResponseController(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		controller := http.NewResponseController(rw)
+		if err := controller.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := controller.SetWriteDeadline(time.Now().Add(time.Second)); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := controller.EnableFullDuplex(); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Write([]byte("ok"))
+	})
+
+	server := httptest.NewServer(rg)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	c.Assert(err, IsNil)
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+
+	c.Assert(res.StatusCode, Equals, 200)
+	c.Assert(string(body), Equals, "ok")
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterIgnoresDuplicateWriteHeader(c *C) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.WriteHeader(http.StatusInternalServerError)
+
+	c.Assert(rw.Status(), Equals, 201)
+	c.Assert(rec.Code, Equals, 201)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterInformationalStatus(c *C) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	called := false
+	rw.Before(func(rw ResponseWriter) {
+		called = true
+	})
+
+	rw.Header().Set("Link", "</app.css>; rel=preload")
+	rw.WriteHeader(http.StatusEarlyHints)
+
+	c.Assert(rw.Written(), Equals, false)
+	c.Assert(rw.Status(), Equals, 0)
+	c.Assert(called, Equals, false)
+
+	rw.WriteHeader(http.StatusOK)
+
+	c.Assert(rw.Status(), Equals, 200)
+	c.Assert(called, Equals, true)
+}
+
+func (s *ResponseWriterSuite) TestResponseWriterHeaderAndBodyWritten(c *C) {
+	rw := newResponseWriter(httptest.NewRecorder())
+
+	rw.WriteHeader(http.StatusNoContent)
+
+	c.Assert(rw.HeaderWritten(), Equals, true)
+	c.Assert(rw.BodyWritten(), Equals, false)
+
+	rw = newResponseWriter(httptest.NewRecorder())
+	rw.Write([]byte("1234"))
+
+	c.Assert(rw.HeaderWritten(), Equals, true)
+	c.Assert(rw.BodyWritten(), Equals, true)
+}
+
+func (s *ResponseWriterSuite) TestLogRequestReportsFirstStatus(c *C) {
+	sw := bytes.NewBuffer(nil)
+	rg := newRouteGroup(httprouter.New())
+	rg.With(LogRequest(log.New(sw, "", 0))).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+		rw.WriteHeader(http.StatusInternalServerError)
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 202)
+	c.Assert(sw.String(), Matches, "(?s).* 202 .*")
+}
@@ -0,0 +1,95 @@
+package webapp
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method string
+
+	// Host is the host pattern of the route, empty for routes on all hosts
+	Host string
+
+	Path        string
+	Handler     string
+	Middlewares int
+
+	// Version is the API version of the route, empty for routes without a version
+	Version string
+
+	// Requirements are the requirements of the guards added to the route with Require
+	Requirements []string
+
+	// Metadata are the values attached to the route with Meta
+	Metadata map[string]interface{}
+}
+
+type routeKey int
+
+const routeInfoKey routeKey = iota
+
+// withRoute makes the route available to the handler and all its middleware
+func withRoute(route *RouteInfo, handler ContextHandler) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		handler(context.WithValue(ctx, routeInfoKey, route), rw, req)
+	}
+}
+
+// currentRoute returns the route handling the request, nil outside of a route
+func currentRoute(ctx context.Context) *RouteInfo {
+	if ctx == nil {
+		return nil
+	}
+	route, _ := ctx.Value(routeInfoKey).(*RouteInfo)
+	return route
+}
+
+type routeTable struct {
+	sync.RWMutex
+	routes      []*RouteInfo
+	versioned   map[string]*versionedRoute
+	constrained map[string]*constrainedRoute
+	spa         []*spaMount
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		versioned:   make(map[string]*versionedRoute),
+		constrained: make(map[string]*constrainedRoute),
+	}
+}
+
+func (table *routeTable) add(route *RouteInfo) {
+	table.Lock()
+	defer table.Unlock()
+
+	table.routes = append(table.routes, route)
+}
+
+// list returns a copy of the registered routes in order of registration
+func (table *routeTable) list() []RouteInfo {
+	table.RLock()
+	defer table.RUnlock()
+
+	routes := make([]RouteInfo, len(table.routes))
+	for i, route := range table.routes {
+		routes[i] = *route
+		routes[i].Requirements = append([]string(nil), route.Requirements...)
+		if route.Metadata != nil {
+			routes[i].Metadata = make(map[string]interface{}, len(route.Metadata))
+			for key, value := range route.Metadata {
+				routes[i].Metadata[key] = value
+			}
+		}
+	}
+	return routes
+}
+
+func handlerName(handler ContextHandler) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
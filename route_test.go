@@ -0,0 +1,69 @@
+package webapp
+
+import (
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"strconv"
+)
+
+type RouteSuite struct{}
+
+var _ = Suite(&RouteSuite{})
+
+func (s *RouteSuite) TestRoutes(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.GET("/", finalHandler)
+	rg.Group("/api", middlewareWriter("a")).With(middlewareWriter("b")).POST("/items", finalHandler)
+
+	routes := rg.Routes()
+
+	c.Assert(routes, HasLen, 2)
+	c.Assert(routes[0].Method, Equals, "GET")
+	c.Assert(routes[0].Path, Equals, "/")
+	c.Assert(routes[0].Middlewares, Equals, 0)
+	c.Assert(routes[1].Method, Equals, "POST")
+	c.Assert(routes[1].Path, Equals, "/api/items")
+	c.Assert(routes[1].Middlewares, Equals, 2)
+	c.Assert(routes[1].Handler, Matches, "github.com/mbict/webapp.*")
+}
+
+func (s *RouteSuite) TestRoutesAreSharedBetweenGroups(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Group("/a").GET("/", finalHandler)
+	rg.With().GET("/b", finalHandler)
+
+	c.Assert(rg.Group("/other").Routes(), HasLen, 2)
+}
+
+func (s *RouteSuite) TestRoutesReturnsCopy(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Require(RequireRoles("admin")).GET("/", finalHandler)
+
+	routes := rg.Routes()
+	routes[0].Path = "/changed"
+	routes[0].Requirements[0] = "changed"
+
+	c.Assert(rg.Routes()[0].Path, Equals, "/")
+	c.Assert(rg.Routes()[0].Requirements, DeepEquals, []string{"roles:admin"})
+}
+
+func (s *RouteSuite) TestGroupsDescribeRoutesConcurrently(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			rg.Require(RequireRoles("other")).Meta("summary", "other")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		rg.Require(RequireRoles("admin")).Meta("summary", "admin").GET("/"+strconv.Itoa(i), finalHandler)
+	}
+	<-done
+
+	for _, route := range rg.Routes() {
+		c.Assert(route.Requirements, DeepEquals, []string{"roles:admin"})
+		c.Assert(route.Metadata, DeepEquals, map[string]interface{}{"summary": "admin"})
+	}
+}
@@ -15,10 +15,20 @@ type RouteGroupSuite struct{}
 
 var _ = Suite(&RouteGroupSuite{})
 
-func doTestRequest(rg http.Handler, method, path string) *httptest.ResponseRecorder {
+// doTestRequest serves the request with the headers, the Host header sets the host of the request
+func doTestRequest(rg http.Handler, method, path string, headers ...map[string]string) *httptest.ResponseRecorder {
 	rw := httptest.NewRecorder()
 	req, _ := http.NewRequest(method, path, nil)
 	req.RemoteAddr = "123.123.123.123"
+	for _, header := range headers {
+		for key, value := range header {
+			if key == "Host" {
+				req.Host = value
+				continue
+			}
+			req.Header.Set(key, value)
+		}
+	}
 	rg.ServeHTTP(rw, req)
 	return rw
 }
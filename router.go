@@ -0,0 +1,145 @@
+package webapp
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// Router is the route tree used by the App and its route groups.
+// Handle should panic on invalid or conflicting paths, the route groups
+// report these panics as registration errors.
+type Router interface {
+	Handle(method, path string, handle RouteHandle)
+
+	// Lookup returns the handle and parameters for the method and path, the bool
+	// reports if a route exists with an extra or without the trailing slash
+	Lookup(method, path string) (RouteHandle, RouteParams, bool)
+
+	ServeHTTP(rw http.ResponseWriter, req *http.Request)
+
+	Options() RouterOptions
+	SetOptions(options RouterOptions)
+}
+
+// RouteHandle is called by a Router for the requests matching a route with the parameters of the path
+type RouteHandle func(rw http.ResponseWriter, req *http.Request, params RouteParams)
+
+// RouteParam is a parameter of a matched path
+type RouteParam struct {
+	Key   string
+	Value string
+}
+
+// RouteParams are the parameters of a matched path in the order of the path
+type RouteParams []RouteParam
+
+// ByName returns the value of the first parameter with the name, empty when there is none
+func (params RouteParams) ByName(name string) string {
+	for _, param := range params {
+		if param.Key == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// httprouterParams converts the parameters to the parameters returned by Params
+func (params RouteParams) httprouterParams() httprouter.Params {
+	if len(params) == 0 {
+		return nil
+	}
+	converted := make(httprouter.Params, len(params))
+	for i, param := range params {
+		converted[i] = httprouter.Param(param)
+	}
+	return converted
+}
+
+func routeParams(params httprouter.Params) RouteParams {
+	if len(params) == 0 {
+		return nil
+	}
+	converted := make(RouteParams, len(params))
+	for i, param := range params {
+		converted[i] = RouteParam(param)
+	}
+	return converted
+}
+
+// RouterOptions are the settings of a Router
+type RouterOptions struct {
+	// RedirectTrailingSlash redirects to the path with or without the trailing slash when only that path has a route
+	RedirectTrailingSlash bool
+
+	// RedirectFixedPath redirects to the cleaned, case insensitive matching path
+	RedirectFixedPath bool
+
+	// HandleOPTIONS answers OPTIONS requests without a route with the allowed methods
+	HandleOPTIONS bool
+
+	// HandleMethodNotAllowed calls MethodNotAllowed when the path only has routes for other methods
+	HandleMethodNotAllowed bool
+
+	// NotFound and MethodNotAllowed default to plain text responses when nil
+	NotFound         http.Handler
+	MethodNotAllowed http.Handler
+}
+
+// DefaultRouterOptions returns the options of a new router, redirecting
+// paths and handling OPTIONS and method not allowed requests
+func DefaultRouterOptions() RouterOptions {
+	return RouterOptions{
+		RedirectTrailingSlash:  true,
+		RedirectFixedPath:      true,
+		HandleOPTIONS:          true,
+		HandleMethodNotAllowed: true,
+	}
+}
+
+// httpRouter adapts the httprouter package, the default router
+type httpRouter struct {
+	*httprouter.Router
+}
+
+// NewHTTPRouter creates a Router backed by github.com/julienschmidt/httprouter
+func NewHTTPRouter() Router {
+	router := &httpRouter{httprouter.New()}
+	router.SetOptions(DefaultRouterOptions())
+	return router
+}
+
+func (router *httpRouter) Handle(method, path string, handle RouteHandle) {
+	router.Router.Handle(method, path, func(rw http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		handle(rw, req, routeParams(params))
+	})
+}
+
+func (router *httpRouter) Lookup(method, path string) (RouteHandle, RouteParams, bool) {
+	handle, params, tsr := router.Router.Lookup(method, path)
+	if handle == nil {
+		return nil, nil, tsr
+	}
+	return func(rw http.ResponseWriter, req *http.Request, params RouteParams) {
+		handle(rw, req, params.httprouterParams())
+	}, routeParams(params), tsr
+}
+
+func (router *httpRouter) Options() RouterOptions {
+	return RouterOptions{
+		RedirectTrailingSlash:  router.RedirectTrailingSlash,
+		RedirectFixedPath:      router.RedirectFixedPath,
+		HandleOPTIONS:          router.HandleOPTIONS,
+		HandleMethodNotAllowed: router.HandleMethodNotAllowed,
+		NotFound:               router.NotFound,
+		MethodNotAllowed:       router.MethodNotAllowed,
+	}
+}
+
+func (router *httpRouter) SetOptions(options RouterOptions) {
+	router.RedirectTrailingSlash = options.RedirectTrailingSlash
+	router.RedirectFixedPath = options.RedirectFixedPath
+	router.HandleOPTIONS = options.HandleOPTIONS
+	router.HandleMethodNotAllowed = options.HandleMethodNotAllowed
+	router.NotFound = options.NotFound
+	router.MethodNotAllowed = options.MethodNotAllowed
+}
@@ -0,0 +1,51 @@
+package webapp
+
+import (
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+)
+
+type RouterSuite struct{}
+
+var _ = Suite(&RouterSuite{})
+
+func (s *RouterSuite) TestHTTPRouterOptions(c *C) {
+	router := NewHTTPRouter()
+	c.Assert(router.Options(), DeepEquals, DefaultRouterOptions())
+
+	options := DefaultRouterOptions()
+	options.RedirectFixedPath = false
+	options.NotFound = http.NotFoundHandler()
+	router.SetOptions(options)
+
+	c.Assert(router.Options().RedirectFixedPath, Equals, false)
+	c.Assert(router.(*httpRouter).Router.NotFound, NotNil)
+}
+
+func (s *RouterSuite) TestHTTPRouterLookup(c *C) {
+	router := NewHTTPRouter()
+	router.Handle("GET", "/users/:id", func(http.ResponseWriter, *http.Request, RouteParams) {})
+
+	handle, params, _ := router.Lookup("GET", "/users/5")
+	c.Assert(handle, NotNil)
+	c.Assert(params.ByName("id"), Equals, "5")
+}
+
+func (s *RouterSuite) TestHTTPRouterHandle(c *C) {
+	router := NewHTTPRouter()
+	router.Handle("GET", "/users/:id", paramsHandle("user"))
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/5", nil)
+	router.ServeHTTP(rw, req)
+
+	c.Assert(rw.Body.String(), Equals, "user id=5")
+}
+
+func (s *RouterSuite) TestRouteParamsByName(c *C) {
+	params := RouteParams{{Key: "id", Value: "5"}, {Key: "id", Value: "6"}}
+
+	c.Assert(params.ByName("id"), Equals, "5")
+	c.Assert(params.ByName("name"), Equals, "")
+}
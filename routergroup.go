@@ -2,12 +2,13 @@ package webapp
 
 import (
 	"context"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"io/fs"
 	"log"
 	"net/http"
 	"path"
-	"reflect"
-	"runtime"
+	"path/filepath"
 )
 
 type (
@@ -17,6 +18,8 @@ type (
 		Use(middleware ...Middleware)
 		With(middleware ...Middleware) RouteGroup
 		Group(relativePath string, middleware ...Middleware) RouteGroup
+		Require(guards ...Guard) RouteGroup
+		Meta(key string, value interface{}) RouteGroup
 
 		POST(relativePath string, handler ContextHandler)
 		GET(relativePath string, handler ContextHandler)
@@ -28,18 +31,47 @@ type (
 		LINK(relativePath string, handler ContextHandler)
 		UNLINK(relativePath string, handler ContextHandler)
 
-		Static(relativePath, directory string)
-		StaticFile(relativePath, file string)
+		Static(relativePath, directory string, options ...StaticOption)
+		StaticFS(relativePath string, fsys fs.FS, options ...StaticOption)
+		StaticFile(relativePath, file string, options ...StaticOption)
+		FileFS(relativePath, file string, fsys fs.FS, options ...StaticOption)
+		Assets(relativePath string, fsys fs.FS, options ...StaticOption) (*Assets, error)
+		SPA(relativePath string, fsys fs.FS, index string, options ...StaticOption)
+
+		Versioning(config VersionConfig) RouteGroup
+		Version(version string, middleware ...Middleware) RouteGroup
 
 		Handle(httpMethod, relativePath string, handler ContextHandler)
+		Register(httpMethod, relativePath string, handler ContextHandler) error
+		Remove(httpMethod, relativePath string) error
+		Replace(httpMethod, relativePath string, handler ContextHandler) error
+		SetMiddleware(middleware ...Middleware) error
 		ServeHTTP(rw http.ResponseWriter, req *http.Request)
+
+		Routes() []RouteInfo
 	}
 
 	routeGroup struct {
 		path       string
 		middleware Chain
-		router     *httprouter.Router
+		router     Router
+		routes     *routeTable
 		logger     *log.Logger
+		app        *webapp
+		host       string
+		versioning *VersionConfig
+		version    *versionScope
+
+		// requirements and metadata are recorded on the routes registered on the group
+		requirements []string
+		metadata     map[string]interface{}
+
+		// parent is the group the group is created from and local the middleware added by the group,
+		// the routes of a lazy App are build with the middleware of all parents at that moment.
+		// Replaced is set when SetMiddleware replaced the middleware of the group.
+		parent   *routeGroup
+		local    Chain
+		replaced bool
 	}
 )
 
@@ -47,7 +79,8 @@ type (
 func newRouteGroup(router *httprouter.Router) RouteGroup {
 	return &routeGroup{
 		path:   "/",
-		router: router,
+		router: &httpRouter{router},
+		routes: newRouteTable(),
 	}
 }
 
@@ -57,9 +90,45 @@ func (group *routeGroup) Logger(logger *log.Logger) {
 }
 
 // Use pushes middleware on the middleware chain
-// be aware that already added routes are not updated
+// be aware that already added routes are not updated, use SetMiddleware to rebuild them
+// or create the App with NewLazy to build the routes after all middleware is added
 func (group *routeGroup) Use(middleware ...Middleware) {
+	if group.app != nil && group.app.lazy {
+		group.app.use(group, middleware)
+		return
+	}
+
 	group.middleware = group.middleware.Append(middleware...)
+	group.local = group.local.Append(middleware...)
+}
+
+// chain returns the middleware of the parents followed by the middleware of the group
+func (group *routeGroup) chain() Chain {
+	if group.parent == nil {
+		return group.local
+	}
+	return group.parent.chain().Append(group.local...)
+}
+
+// entryChain returns the middleware for the routes registered on the group, below a group
+// of which the middleware is replaced the middleware is build from the parents
+func (group *routeGroup) entryChain() Chain {
+	for scope := group; scope != nil; scope = scope.parent {
+		if scope.replaced {
+			return group.chain()
+		}
+	}
+	return group.middleware
+}
+
+// descendsFrom reports if the group is the ancestor or is created from it
+func (group *routeGroup) descendsFrom(ancestor *routeGroup) bool {
+	for scope := group; scope != nil; scope = scope.parent {
+		if scope == ancestor {
+			return true
+		}
+	}
+	return false
 }
 
 // With creates a new Group with the same path and pushes the new middleware to stack
@@ -69,7 +138,17 @@ func (group *routeGroup) With(middleware ...Middleware) RouteGroup {
 		path:       group.path,
 		middleware: group.middleware.Append(middleware...),
 		router:     group.router,
+		routes:     group.routes,
 		logger:     group.logger,
+		app:        group.app,
+		host:       group.host,
+		versioning: group.versioning,
+		version:    group.version,
+		parent:     group,
+		local:      NewChain(middleware...),
+
+		requirements: group.requirements,
+		metadata:     group.metadata,
 	}
 }
 
@@ -79,7 +158,17 @@ func (group *routeGroup) Group(relativePath string, middleware ...Middleware) Ro
 		path:       group.calculateAbsolutePath(relativePath),
 		middleware: group.middleware.Append(middleware...),
 		router:     group.router,
+		routes:     group.routes,
 		logger:     group.logger,
+		app:        group.app,
+		host:       group.host,
+		versioning: group.versioning,
+		version:    group.version,
+		parent:     group,
+		local:      NewChain(middleware...),
+
+		requirements: group.requirements,
+		metadata:     group.metadata,
 	}
 }
 
@@ -139,62 +228,162 @@ func (group *routeGroup) UNLINK(relativePath string, handler ContextHandler) {
 // frequently used, non-standardized or custom methods (e.group. for internal
 // communication with a proxy).
 func (group *routeGroup) Handle(httpMethod, relativePath string, handler ContextHandler) {
+	if err := group.Register(httpMethod, relativePath, handler); err != nil {
+		panic(err)
+	}
+}
+
+// Register registers the handler as Handle does, an invalid path or a path
+// conflicting with an existing route is returned as error instead of a panic.
+//
+// Parameters can be constrained with a named constraint or a regular expression,
+// requests with parameters not matching the constraints are handled as not found.
+// Routes only differing in their parameter names or constraints are tried in order of registration,
+// regardless of the order a route without constraints is tried last.
+//     router.GET("/users/:id<int>", user)
+//     router.GET("/users/:name<[a-z]+>", userByName)
+//
+// Routes can be registered on the groups of an App while it is serving,
+// the routes of the App are atomically replaced by the routes including the new route.
+func (group *routeGroup) Register(httpMethod, relativePath string, handler ContextHandler) error {
+	if group.app == nil {
+		return group.addRoute(httpMethod, relativePath, handler)
+	}
+
+	entry := group.newEntry(httpMethod, group.calculateAbsolutePath(relativePath), func(bound *routeGroup) error {
+		return bound.addRoute(httpMethod, relativePath, handler)
+	})
+	return group.app.addEntry(entry)
+}
+
+// addRoute registers the handler on the router of the group
+func (group *routeGroup) addRoute(httpMethod, relativePath string, handler ContextHandler) (err error) {
 	absolutePath := group.calculateAbsolutePath(relativePath)
+	pattern, err := parseRoutePattern(absolutePath)
+	if err != nil {
+		return err
+	}
+	if group.version != nil && pattern.constrained() {
+		return fmt.Errorf("route %s: constraints are not supported on versioned routes", absolutePath)
+	}
+
+	route := &RouteInfo{
+		Method:      httpMethod,
+		Host:        group.host,
+		Path:        absolutePath,
+		Handler:     handlerName(handler),
+		Middlewares: len(group.middleware),
+
+		Requirements: group.requirements,
+		Metadata:     group.metadata,
+	}
+	if group.version != nil {
+		route.Version = group.version.name
+	}
 	handler = group.middleware.Then(handler)
+	handler = withRoute(route, handler)
 
 	//debug route logging
 	if group.logger != nil {
-		handlerName := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
-		group.logger.Printf("%-7s %-35s --> %s (%d middlewares)\n", httpMethod, absolutePath, handlerName, len(group.middleware))
+		group.logger.Printf("%-7s %-35s --> %s (%d middlewares)\n", httpMethod, absolutePath, handlerName(handler), len(group.middleware))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("route %s %s: %v", httpMethod, absolutePath, r)
+		}
+	}()
+
+	switch {
+	case group.version != nil:
+		group.handleVersion(route, handler)
+		return nil
+	case len(pattern.names) > 0:
+		group.handleConstrained(httpMethod, pattern, handler)
+	default:
+		group.router.Handle(httpMethod, absolutePath, routeHandle(handler))
 	}
+	group.routes.add(route)
+	return nil
+}
 
-	group.router.Handle(httpMethod, absolutePath, func(rw http.ResponseWriter, req *http.Request, params httprouter.Params) {
-		ctx := newContextWithParams(context.Background(), params)
+// routeHandle creates the router handle calling the handler with the route parameters in the context
+func routeHandle(handler ContextHandler) RouteHandle {
+	return func(rw http.ResponseWriter, req *http.Request, params RouteParams) {
+		ctx := newContextWithParams(context.Background(), withHostParams(req, params).httprouterParams())
 		rw = newResponseWriter(rw)
 		handler(ctx, rw, req)
-	})
+	}
+}
+
+// Routes returns the routes registered on the router in order of registration
+func (group *routeGroup) Routes() []RouteInfo {
+	if group.app != nil {
+		return group.app.current().routes.list()
+	}
+	return group.routes.list()
 }
 
 // ServeHTTP
 func (group *routeGroup) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if group.app != nil {
+		if !group.app.isServing() {
+			group.app.startServing()
+		}
+		group.app.current().hostRouter(group.host).ServeHTTP(rw, req)
+		return
+	}
 	group.router.ServeHTTP(rw, req)
 }
 
 // Static serves files from the given file system root.
 // Internally a http.FileServer is used, therefore http.NotFound is used instead
-// of the Router's NotFound handler.
+// of the Router's NotFound handler unless the StaticNotFound option is given.
 // To use the operating system's file system implementation,
 // use :
 //     router.Static("/static", "/var/www")
-func (group *routeGroup) Static(relativePath, root string) {
-	absolutePath := group.calculateAbsolutePath(relativePath)
-	handler := createStaticHandler(absolutePath, root)
+func (group *routeGroup) Static(relativePath, root string, options ...StaticOption) {
+	group.static(relativePath, http.Dir(root), options)
+}
+
+// StaticFS serves files from the given fs.FS, e.g. an embed.FS
+//     //go:embed assets
+//     var assets embed.FS
+//
+//     router.StaticFS("/assets", assets)
+func (group *routeGroup) StaticFS(relativePath string, fsys fs.FS, options ...StaticOption) {
+	group.static(relativePath, http.FS(fsys), options)
+}
+
+func (group *routeGroup) static(relativePath string, fileSystem http.FileSystem, options []StaticOption) {
+	handler := group.createStaticHandler(fileSystem, newStaticConfig(options))
 	relativePath = path.Join(relativePath, "/*filepath")
 
 	group.GET(relativePath, handler)
 	group.HEAD(relativePath, handler)
 }
 
-func createStaticHandler(absolutePath, root string) ContextHandler {
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(http.Dir(root)))
-	return func(_ context.Context, rw http.ResponseWriter, req *http.Request) {
-		fileServer.ServeHTTP(rw, req)
+// StaticFile serves a single file from the operating system's file system
+func (group *routeGroup) StaticFile(relativePath, file string, options ...StaticOption) {
+	dir, name := filepath.Split(file)
+	if dir == "" {
+		dir = "."
 	}
+	group.staticFile(relativePath, http.Dir(dir), name, options)
+}
+
+// FileFS serves a single file from the given fs.FS
+func (group *routeGroup) FileFS(relativePath, file string, fsys fs.FS, options ...StaticOption) {
+	group.staticFile(relativePath, http.FS(fsys), file, options)
 }
 
-func (group *routeGroup) StaticFile(relativePath, file string) {
-	handler := createStaticFileHandler(file)
+func (group *routeGroup) staticFile(relativePath string, fileSystem http.FileSystem, file string, options []StaticOption) {
+	handler := group.createStaticFileHandler(fileSystem, file, newStaticConfig(options))
 
 	group.GET(relativePath, handler)
 	group.HEAD(relativePath, handler)
 }
 
-func createStaticFileHandler(file string) ContextHandler {
-	return func(_ context.Context, rw http.ResponseWriter, req *http.Request) {
-		http.ServeFile(rw, req, file)
-	}
-}
-
 func (group *routeGroup) calculateAbsolutePath(relativePath string) string {
 	if len(relativePath) == 0 {
 		return group.path
@@ -0,0 +1,258 @@
+package webapp
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var errNoApp = errors.New("runtime route changes require a route group of an app")
+
+// routeTree holds the routers of an App and the routes registered on them. Routes changed
+// while the App is serving are applied to a new tree which atomically replaces the current one,
+// requests already being handled finish on the tree they started on.
+type routeTree struct {
+	router Router
+	hosts  []*hostRoute
+	routes *routeTable
+}
+
+// routeEntry is a registration on an App, the entries are replayed in order when a new tree is build
+type routeEntry struct {
+	group      *routeGroup
+	method     string
+	path       string
+	version    string
+	middleware Chain
+
+	// apply registers the route on the group bound to a tree
+	apply func(group *routeGroup) error
+}
+
+// newEntry creates the entry for a route registered on the group with its current middleware
+func (group *routeGroup) newEntry(method, path string, apply func(group *routeGroup) error) *routeEntry {
+	entry := &routeEntry{
+		group:      group,
+		method:     method,
+		path:       path,
+		middleware: group.entryChain(),
+		apply:      apply,
+	}
+	if group.version != nil {
+		entry.version = group.version.name
+	}
+	return entry
+}
+
+// bind returns a copy of the group of the entry registering its routes on the tree
+func (entry *routeEntry) bind(tree *routeTree) *routeGroup {
+	bound := *entry.group
+	bound.router = tree.hostRouter(entry.group.host)
+	bound.routes = tree.routes
+	bound.middleware = entry.middleware
+	if entry.group.app.lazy {
+		bound.middleware = entry.group.chain()
+	}
+	return &bound
+}
+
+// matches reports if the entry is the route for the method and path on the host of the group,
+// for a group of a version only the route of that version matches
+func (entry *routeEntry) matches(group *routeGroup, method, path string) bool {
+	if entry.group.host != group.host || entry.method != method || entry.path != path {
+		return false
+	}
+	return group.version == nil || normalizeVersion(entry.version) == normalizeVersion(group.version.name)
+}
+
+func (tree *routeTree) hostRouter(host string) Router {
+	for _, route := range tree.hosts {
+		if route.pattern == host {
+			return route.router
+		}
+	}
+	return tree.router
+}
+
+// newTree creates an empty tree with routers for the hosts using the options of the App
+func (app *webapp) newTree(hosts []*hostRoute) *routeTree {
+	tree := &routeTree{
+		router: app.newRouter(),
+		routes: newRouteTable(),
+	}
+
+	tree.router.SetOptions(app.options)
+	for _, host := range hosts {
+		router := app.newRouter()
+		router.SetOptions(app.options)
+		tree.hosts = append(tree.hosts, &hostRoute{
+			pattern: host.pattern,
+			labels:  host.labels,
+			router:  router,
+		})
+	}
+	return tree
+}
+
+// current returns the tree handling the requests
+func (app *webapp) current() *routeTree {
+	tree, _ := app.tree.Load().(*routeTree)
+	return tree
+}
+
+func (app *webapp) isServing() bool {
+	return atomic.LoadInt32(&app.serving) == 1
+}
+
+// addEntry registers the route of the entry. Before the App serves its first request the route is added
+// to the current tree, after that a new tree with all routes is build and replaces the current tree.
+// A lazy App only records the entry until it is build.
+func (app *webapp) addEntry(entry *routeEntry) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.lazy && !app.built {
+		app.entries = append(app.entries, entry)
+		return nil
+	}
+
+	if !app.isServing() {
+		if err := entry.apply(entry.bind(app.current())); err != nil {
+			return err
+		}
+		app.entries = append(app.entries, entry)
+		return nil
+	}
+
+	entries := append(app.entries[:len(app.entries):len(app.entries)], entry)
+	return app.swap(app.current().hosts, entries)
+}
+
+// change replaces the entries with the result of fn and swaps in the tree build from them
+func (app *webapp) change(fn func(entries []*routeEntry) ([]*routeEntry, error)) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	entries, err := fn(app.entries)
+	if err != nil {
+		return err
+	}
+	return app.swap(app.current().hosts, entries)
+}
+
+// swap builds a tree from the entries and makes it the current tree,
+// the current tree is kept when one of the routes fails to register.
+// The routes of a lazy App are only build after Build is called.
+func (app *webapp) swap(hosts []*hostRoute, entries []*routeEntry) error {
+	if app.lazy && !app.built {
+		app.entries = entries
+		return nil
+	}
+
+	tree := app.newTree(hosts)
+	for _, entry := range entries {
+		if err := entry.apply(entry.bind(tree)); err != nil {
+			return err
+		}
+	}
+
+	app.entries = entries
+	app.tree.Store(tree)
+	return nil
+}
+
+// Remove removes the route for the method and path from the host of the group, the
+// route is removed for all versions unless the group is created by Version.
+// It is safe to call while the App is serving, the routes of the App are atomically replaced.
+func (group *routeGroup) Remove(httpMethod, relativePath string) error {
+	if group.app == nil {
+		return errNoApp
+	}
+
+	absolutePath := group.calculateAbsolutePath(relativePath)
+	return group.app.change(func(entries []*routeEntry) ([]*routeEntry, error) {
+		var kept []*routeEntry
+		for _, entry := range entries {
+			if !entry.matches(group, httpMethod, absolutePath) {
+				kept = append(kept, entry)
+			}
+		}
+
+		if len(kept) == len(entries) {
+			return nil, fmt.Errorf("route %s %s is not registered", httpMethod, absolutePath)
+		}
+		return kept, nil
+	})
+}
+
+// Replace replaces the handler of the route for the method and path with the handler
+// and the middleware of the group. It is safe to call while the App is serving.
+//
+//     if err := app.Replace("GET", "/search", searchV2); err != nil {
+//         log.Println(err)
+//     }
+func (group *routeGroup) Replace(httpMethod, relativePath string, handler ContextHandler) error {
+	if group.app == nil {
+		return errNoApp
+	}
+
+	absolutePath := group.calculateAbsolutePath(relativePath)
+	replacement := group.newEntry(httpMethod, absolutePath, func(bound *routeGroup) error {
+		return bound.addRoute(httpMethod, relativePath, handler)
+	})
+
+	return group.app.change(func(entries []*routeEntry) ([]*routeEntry, error) {
+		var replaced []*routeEntry
+		found := false
+		for _, entry := range entries {
+			switch {
+			case !entry.matches(group, httpMethod, absolutePath):
+				replaced = append(replaced, entry)
+			case !found:
+				replaced = append(replaced, replacement)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("route %s %s is not registered", httpMethod, absolutePath)
+		}
+		return replaced, nil
+	})
+}
+
+// SetMiddleware replaces the middleware of the group, including the middleware inherited
+// from its parent, and rebuilds the routes registered on the group with it.
+// The routes of groups created from the group are rebuild with the new middleware
+// followed by the middleware added by those groups.
+// It is safe to call while the App is serving.
+func (group *routeGroup) SetMiddleware(middleware ...Middleware) error {
+	if group.app == nil {
+		return errNoApp
+	}
+
+	chain := NewChain(middleware...)
+	app := group.app
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	previous, local, parent, replaced := group.middleware, group.local, group.parent, group.replaced
+	group.middleware, group.local, group.parent, group.replaced = chain, chain, nil, true
+
+	entries := make([]*routeEntry, len(app.entries))
+	for i, entry := range app.entries {
+		entries[i] = entry
+		if entry.group.descendsFrom(group) {
+			updated := *entry
+			updated.middleware = entry.group.entryChain()
+			entries[i] = &updated
+		}
+	}
+
+	if err := app.swap(app.current().hosts, entries); err != nil {
+		group.middleware, group.local, group.parent, group.replaced = previous, local, parent, replaced
+		return err
+	}
+	return nil
+}
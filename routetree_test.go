@@ -0,0 +1,191 @@
+package webapp
+
+import (
+	"context"
+	"fmt"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"sync"
+)
+
+type RouteTreeSuite struct{}
+
+var _ = Suite(&RouteTreeSuite{})
+
+func headerMiddleware(value string) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Add("X-Middleware", value)
+			next(ctx, rw, req)
+		}
+	}
+}
+
+func (s *RouteTreeSuite) TestRegisterWhileServing(c *C) {
+	app := New()
+	app.GET("/", writeHandler("index"))
+	c.Assert(doTestRequest(app, "GET", "/").Body.String(), Equals, "index")
+
+	app.GET("/feature", writeHandler("feature"))
+	c.Assert(doTestRequest(app, "GET", "/feature").Body.String(), Equals, "feature")
+	c.Assert(doTestRequest(app, "GET", "/").Body.String(), Equals, "index")
+	c.Assert(app.Routes(), HasLen, 2)
+
+	err := app.Register("GET", "/feature", writeHandler("duplicate"))
+	c.Assert(err, ErrorMatches, "route GET /feature: .*")
+	c.Assert(doTestRequest(app, "GET", "/feature").Body.String(), Equals, "feature")
+	c.Assert(app.Routes(), HasLen, 2)
+}
+
+func (s *RouteTreeSuite) TestRemove(c *C) {
+	app := New()
+	api := app.Group("/api")
+	api.GET("/users", writeHandler("users"))
+	api.POST("/users", writeHandler("create"))
+	doTestRequest(app, "GET", "/api/users")
+
+	c.Assert(api.Remove("POST", "/users"), IsNil)
+	c.Assert(doTestRequest(app, "GET", "/api/users").Body.String(), Equals, "users")
+	c.Assert(doTestRequest(app, "POST", "/api/users").Code, Equals, http.StatusMethodNotAllowed)
+
+	routes := app.Routes()
+	c.Assert(routes, HasLen, 1)
+	c.Assert(routes[0].Method, Equals, "GET")
+
+	c.Assert(api.Remove("POST", "/users"), ErrorMatches, "route POST /api/users is not registered")
+}
+
+func (s *RouteTreeSuite) TestRemoveVersion(c *C) {
+	app := New()
+	api := app.Group("/api")
+	api.Version("v1").GET("/users", writeHandler("v1"))
+	api.Version("v2").GET("/users", writeHandler("v2"))
+
+	c.Assert(api.Version("v2").Remove("GET", "/users"), IsNil)
+	c.Assert(doTestRequest(app, "GET", "/api/users", map[string]string{"API-Version": "2"}).Code, Equals, http.StatusNotFound)
+	c.Assert(doTestRequest(app, "GET", "/api/users", map[string]string{"API-Version": "1"}).Body.String(), Equals, "v1")
+	c.Assert(doTestRequest(app, "GET", "/api/v2/users").Code, Equals, http.StatusNotFound)
+}
+
+func (s *RouteTreeSuite) TestReplace(c *C) {
+	app := New()
+	app.GET("/search", writeHandler("search v1"))
+	app.GET("/about", writeHandler("about"))
+	doTestRequest(app, "GET", "/search")
+
+	c.Assert(app.With(headerMiddleware("v2")).Replace("GET", "/search", writeHandler("search v2")), IsNil)
+
+	rw := doTestRequest(app, "GET", "/search")
+	c.Assert(rw.Body.String(), Equals, "search v2")
+	c.Assert(rw.Header().Get("X-Middleware"), Equals, "v2")
+
+	routes := app.Routes()
+	c.Assert(routes, HasLen, 2)
+	c.Assert(routes[0].Path, Equals, "/search")
+	c.Assert(routes[0].Middlewares, Equals, 1)
+
+	c.Assert(app.Replace("GET", "/contact", writeHandler("contact")), ErrorMatches, "route GET /contact is not registered")
+}
+
+func (s *RouteTreeSuite) TestSetMiddleware(c *C) {
+	app := New()
+	admin := app.Group("/admin", headerMiddleware("admin"))
+	admin.GET("/", writeHandler("dashboard"))
+	app.GET("/", writeHandler("index"))
+	doTestRequest(app, "GET", "/")
+
+	c.Assert(admin.SetMiddleware(headerMiddleware("audit"), headerMiddleware("admin")), IsNil)
+
+	rw := doTestRequest(app, "GET", "/admin/")
+	c.Assert(rw.Body.String(), Equals, "dashboard")
+	c.Assert(rw.Header()["X-Middleware"], DeepEquals, []string{"audit", "admin"})
+	c.Assert(doTestRequest(app, "GET", "/").Header().Get("X-Middleware"), Equals, "")
+
+	c.Assert(app.SetMiddleware(headerMiddleware("app")), IsNil)
+	c.Assert(doTestRequest(app, "GET", "/").Header().Get("X-Middleware"), Equals, "app")
+	c.Assert(doTestRequest(app, "GET", "/missing").Header().Get("X-Middleware"), Equals, "app")
+}
+
+func (s *RouteTreeSuite) TestSetMiddlewareOfParentGroup(c *C) {
+	app := New()
+	api := app.Group("/api", headerMiddleware("api"))
+	api.GET("/", writeHandler("index"))
+	api.With(headerMiddleware("with")).GET("/with", writeHandler("with"))
+	users := api.Group("/users", headerMiddleware("users"))
+	users.GET("/", writeHandler("users"))
+	doTestRequest(app, "GET", "/api/")
+
+	c.Assert(api.SetMiddleware(headerMiddleware("audit")), IsNil)
+	users.GET("/new", writeHandler("new"))
+
+	c.Assert(doTestRequest(app, "GET", "/api/").Header()["X-Middleware"], DeepEquals, []string{"audit"})
+	c.Assert(doTestRequest(app, "GET", "/api/with").Header()["X-Middleware"], DeepEquals, []string{"audit", "with"})
+	c.Assert(doTestRequest(app, "GET", "/api/users/").Header()["X-Middleware"], DeepEquals, []string{"audit", "users"})
+	c.Assert(doTestRequest(app, "GET", "/api/users/new").Header()["X-Middleware"], DeepEquals, []string{"audit", "users"})
+}
+
+func (s *RouteTreeSuite) TestHostWhileServing(c *C) {
+	app := New()
+	app.GET("/", writeHandler("main"))
+	doTestRequest(app, "GET", "/")
+
+	app.Host("api.example.com").GET("/", writeHandler("api"))
+	c.Assert(doTestRequest(app, "GET", "/", map[string]string{"Host": "api.example.com"}).Body.String(), Equals, "api")
+	c.Assert(doTestRequest(app, "GET", "/", map[string]string{"Host": "www.example.com"}).Body.String(), Equals, "main")
+}
+
+func (s *RouteTreeSuite) TestRequiresApp(c *C) {
+	rg := newRouteGroup(nil)
+	c.Assert(rg.Remove("GET", "/"), Equals, errNoApp)
+	c.Assert(rg.Replace("GET", "/", writeHandler("")), Equals, errNoApp)
+	c.Assert(rg.SetMiddleware(), Equals, errNoApp)
+}
+
+func (s *RouteTreeSuite) TestConcurrentChanges(c *C) {
+	app := New()
+	app.GET("/", writeHandler("index"))
+	doTestRequest(app, "GET", "/")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			app.GET(fmt.Sprintf("/feature/%d", i), writeHandler("feature"))
+		}(i)
+		go func() {
+			defer wg.Done()
+			c.Check(doTestRequest(app, "GET", "/").Body.String(), Equals, "index")
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(app.Routes(), HasLen, 11)
+	c.Assert(doTestRequest(app, "GET", "/feature/7").Body.String(), Equals, "feature")
+}
+
+func (s *RouteTreeSuite) TestChangeHandlersWhileServing(c *C) {
+	app := New()
+	app.GET("/", writeHandler("index"))
+	doTestRequest(app, "GET", "/")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c.Check(app.SetMiddleware(headerMiddleware(fmt.Sprint(i))), IsNil)
+			app.NotFound(writeHandler("missing"))
+			app.HandleOptions(i%2 == 0)
+		}(i)
+		go func() {
+			defer wg.Done()
+			c.Check(doTestRequest(app, "GET", "/").Body.String(), Equals, "index")
+			doTestRequest(app, "GET", "/missing")
+			doTestRequest(app, "POST", "/")
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(doTestRequest(app, "GET", "/missing").Body.String(), Equals, "missing")
+}
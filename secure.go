@@ -0,0 +1,293 @@
+package webapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type secureKey int
+
+const cspNonceKey secureKey = iota
+
+// Content security policy source keywords
+const (
+	CSPSelf          = "'self'"
+	CSPNone          = "'none'"
+	CSPUnsafeInline  = "'unsafe-inline'"
+	CSPUnsafeEval    = "'unsafe-eval'"
+	CSPStrictDynamic = "'strict-dynamic'"
+
+	// CSPNonceSource is a placeholder source, it is replaced with the
+	// nonce generated for every request ('nonce-<value>')
+	CSPNonceSource = "'nonce'"
+)
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// CSP builds a Content-Security-Policy header value.
+//
+//     csp := webapp.NewCSP().
+//         DefaultSrc(webapp.CSPSelf).
+//         ScriptSrc(webapp.CSPSelf, webapp.CSPNonceSource).
+//         ObjectSrc(webapp.CSPNone)
+//
+// When CSPNonceSource is used as source a new nonce is generated for every request,
+// retrieve it using CSPNonce(ctx) to use in script or style tags.
+type CSP struct {
+	directives []cspDirective
+}
+
+// NewCSP creates a new empty content security policy
+func NewCSP() *CSP {
+	return &CSP{}
+}
+
+// Directive sets the directive with the given sources, an already present
+// directive with the same name is replaced
+func (csp *CSP) Directive(name string, sources ...string) *CSP {
+	for i := range csp.directives {
+		if csp.directives[i].name == name {
+			csp.directives[i].sources = sources
+			return csp
+		}
+	}
+	csp.directives = append(csp.directives, cspDirective{name: name, sources: sources})
+	return csp
+}
+
+// DefaultSrc sets the default-src directive
+func (csp *CSP) DefaultSrc(sources ...string) *CSP {
+	return csp.Directive("default-src", sources...)
+}
+
+// ScriptSrc sets the script-src directive
+func (csp *CSP) ScriptSrc(sources ...string) *CSP {
+	return csp.Directive("script-src", sources...)
+}
+
+// StyleSrc sets the style-src directive
+func (csp *CSP) StyleSrc(sources ...string) *CSP {
+	return csp.Directive("style-src", sources...)
+}
+
+// ImgSrc sets the img-src directive
+func (csp *CSP) ImgSrc(sources ...string) *CSP {
+	return csp.Directive("img-src", sources...)
+}
+
+// ConnectSrc sets the connect-src directive
+func (csp *CSP) ConnectSrc(sources ...string) *CSP {
+	return csp.Directive("connect-src", sources...)
+}
+
+// FontSrc sets the font-src directive
+func (csp *CSP) FontSrc(sources ...string) *CSP {
+	return csp.Directive("font-src", sources...)
+}
+
+// ObjectSrc sets the object-src directive
+func (csp *CSP) ObjectSrc(sources ...string) *CSP {
+	return csp.Directive("object-src", sources...)
+}
+
+// MediaSrc sets the media-src directive
+func (csp *CSP) MediaSrc(sources ...string) *CSP {
+	return csp.Directive("media-src", sources...)
+}
+
+// FrameSrc sets the frame-src directive
+func (csp *CSP) FrameSrc(sources ...string) *CSP {
+	return csp.Directive("frame-src", sources...)
+}
+
+// FrameAncestors sets the frame-ancestors directive
+func (csp *CSP) FrameAncestors(sources ...string) *CSP {
+	return csp.Directive("frame-ancestors", sources...)
+}
+
+// BaseURI sets the base-uri directive
+func (csp *CSP) BaseURI(sources ...string) *CSP {
+	return csp.Directive("base-uri", sources...)
+}
+
+// FormAction sets the form-action directive
+func (csp *CSP) FormAction(sources ...string) *CSP {
+	return csp.Directive("form-action", sources...)
+}
+
+// ReportURI sets the report-uri directive
+func (csp *CSP) ReportURI(uri string) *CSP {
+	return csp.Directive("report-uri", uri)
+}
+
+// UpgradeInsecureRequests adds the upgrade-insecure-requests directive
+func (csp *CSP) UpgradeInsecureRequests() *CSP {
+	return csp.Directive("upgrade-insecure-requests")
+}
+
+// usesNonce reports if any of the directives contains the nonce placeholder
+func (csp *CSP) usesNonce() bool {
+	for _, directive := range csp.directives {
+		for _, source := range directive.sources {
+			if source == CSPNonceSource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String returns the policy as header value, nonce placeholders are left untouched
+func (csp *CSP) String() string {
+	return csp.build("", false)
+}
+
+// build creates the header value, replaces the nonce placeholder with the nonce
+// and drops the upgrade-insecure-requests directive in development mode
+func (csp *CSP) build(nonce string, development bool) string {
+	parts := make([]string, 0, len(csp.directives))
+	for _, directive := range csp.directives {
+		if development && directive.name == "upgrade-insecure-requests" {
+			continue
+		}
+
+		part := directive.name
+		for _, source := range directive.sources {
+			if source == CSPNonceSource && nonce != "" {
+				source = fmt.Sprintf("'nonce-%s'", nonce)
+			}
+			part += " " + source
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// SecureConfig holds the headers set by the Secure middleware.
+// Empty values are not sent.
+type SecureConfig struct {
+	// STSSeconds is the max-age of the Strict-Transport-Security header, 0 disables the header
+	STSSeconds           int64
+	STSIncludeSubdomains bool
+	STSPreload           bool
+
+	// ContentTypeNosniff sets the X-Content-Type-Options header to nosniff
+	ContentTypeNosniff bool
+
+	// FrameOptions is the value of the X-Frame-Options header (DENY, SAMEORIGIN)
+	FrameOptions string
+
+	// ReferrerPolicy is the value of the Referrer-Policy header
+	ReferrerPolicy string
+
+	// ContentSecurityPolicy is the policy sent in the Content-Security-Policy header
+	ContentSecurityPolicy *CSP
+
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only
+	CSPReportOnly bool
+
+	// Development relaxes the headers, the Strict-Transport-Security header is not sent,
+	// the upgrade-insecure-requests directive is dropped and the policy is only reported
+	Development bool
+}
+
+// DefaultSecureConfig returns a config with sane defaults,
+// the development relaxations are enabled when Env() is development
+func DefaultSecureConfig() SecureConfig {
+	return SecureConfig{
+		STSSeconds:           31536000,
+		STSIncludeSubdomains: true,
+		ContentTypeNosniff:   true,
+		FrameOptions:         "DENY",
+		ReferrerPolicy:       "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: NewCSP().
+			DefaultSrc(CSPSelf).
+			ObjectSrc(CSPNone).
+			BaseURI(CSPSelf).
+			FrameAncestors(CSPNone),
+		Development: Env() == Development,
+	}
+}
+
+// Secure is a middleware that sets the security related response headers.
+// Use DefaultSecureConfig() for sane defaults.
+func Secure(config SecureConfig) Middleware {
+	sts := ""
+	if config.STSSeconds > 0 && !config.Development {
+		sts = fmt.Sprintf("max-age=%d", config.STSSeconds)
+		if config.STSIncludeSubdomains {
+			sts += "; includeSubDomains"
+		}
+		if config.STSPreload {
+			sts += "; preload"
+		}
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly || config.Development {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	csp := config.ContentSecurityPolicy
+	useNonce := csp != nil && csp.usesNonce()
+	policy := ""
+	if csp != nil && !useNonce {
+		policy = csp.build("", config.Development)
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			header := rw.Header()
+
+			if sts != "" {
+				header.Set("Strict-Transport-Security", sts)
+			}
+			if config.ContentTypeNosniff {
+				header.Set("X-Content-Type-Options", "nosniff")
+			}
+			if config.FrameOptions != "" {
+				header.Set("X-Frame-Options", config.FrameOptions)
+			}
+			if config.ReferrerPolicy != "" {
+				header.Set("Referrer-Policy", config.ReferrerPolicy)
+			}
+
+			if useNonce {
+				nonce := generateNonce()
+				ctx = context.WithValue(ctx, cspNonceKey, nonce)
+				header.Set(cspHeader, csp.build(nonce, config.Development))
+			} else if policy != "" {
+				header.Set(cspHeader, policy)
+			}
+
+			next(ctx, rw, req)
+		}
+	}
+}
+
+// CSPNonce retrieves the content security policy nonce for the current request
+// from the context, an empty string is returned when no nonce is present
+func CSPNonce(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if nonce, ok := ctx.Value(cspNonceKey).(string); ok {
+		return nonce
+	}
+
+	return ""
+}
+
+func generateNonce() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return base64.StdEncoding.EncodeToString(buf[:])
+}
@@ -0,0 +1,108 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"strings"
+)
+
+type SecureSuite struct{}
+
+var _ = Suite(&SecureSuite{})
+
+func (s *SecureSuite) TestCSPBuilder(c *C) {
+	csp := NewCSP().
+		DefaultSrc(CSPSelf).
+		ScriptSrc(CSPSelf, "https://cdn.example.com").
+		ObjectSrc(CSPNone).
+		UpgradeInsecureRequests()
+
+	c.Assert(csp.String(), Equals, "default-src 'self'; script-src 'self' https://cdn.example.com; object-src 'none'; upgrade-insecure-requests")
+}
+
+func (s *SecureSuite) TestCSPDirectiveReplacesExisting(c *C) {
+	csp := NewCSP().DefaultSrc(CSPSelf).DefaultSrc(CSPNone)
+
+	c.Assert(csp.String(), Equals, "default-src 'none'")
+}
+
+func (s *SecureSuite) TestSecureDefaultHeaders(c *C) {
+	config := DefaultSecureConfig()
+	config.Development = false
+
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Secure(config)).GET("/", finalHandler)
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Header().Get("Strict-Transport-Security"), Equals, "max-age=31536000; includeSubDomains")
+	c.Assert(response.Header().Get("X-Content-Type-Options"), Equals, "nosniff")
+	c.Assert(response.Header().Get("X-Frame-Options"), Equals, "DENY")
+	c.Assert(response.Header().Get("Referrer-Policy"), Equals, "strict-origin-when-cross-origin")
+	c.Assert(response.Header().Get("Content-Security-Policy"), Equals, "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'")
+}
+
+func (s *SecureSuite) TestSecureDevelopmentRelaxations(c *C) {
+	config := DefaultSecureConfig()
+	config.Development = true
+	config.ContentSecurityPolicy.UpgradeInsecureRequests()
+
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Secure(config)).GET("/", finalHandler)
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Header().Get("Strict-Transport-Security"), Equals, "")
+	c.Assert(response.Header().Get("Content-Security-Policy"), Equals, "")
+	c.Assert(response.Header().Get("Content-Security-Policy-Report-Only"), Equals, "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'")
+}
+
+func (s *SecureSuite) TestDefaultSecureConfigFollowsEnv(c *C) {
+	defer SetEnv(Env())
+
+	SetEnv(Production)
+	c.Assert(DefaultSecureConfig().Development, Equals, false)
+
+	SetEnv(Development)
+	c.Assert(DefaultSecureConfig().Development, Equals, true)
+}
+
+func (s *SecureSuite) TestSecureNonce(c *C) {
+	config := SecureConfig{
+		ContentSecurityPolicy: NewCSP().ScriptSrc(CSPSelf, CSPNonceSource),
+	}
+
+	var nonces []string
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Secure(config)).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		nonces = append(nonces, CSPNonce(ctx))
+	})
+
+	response1 := doTestRequest(rg, "GET", "/")
+	response2 := doTestRequest(rg, "GET", "/")
+
+	c.Assert(nonces, HasLen, 2)
+	c.Assert(nonces[0], Not(Equals), "")
+	c.Assert(nonces[0], Not(Equals), nonces[1])
+	c.Assert(response1.Header().Get("Content-Security-Policy"), Equals, "script-src 'self' 'nonce-"+nonces[0]+"'")
+	c.Assert(response2.Header().Get("Content-Security-Policy"), Equals, "script-src 'self' 'nonce-"+nonces[1]+"'")
+}
+
+func (s *SecureSuite) TestCSPNonceWithoutMiddleware(c *C) {
+	c.Assert(CSPNonce(nil), Equals, "")
+	c.Assert(CSPNonce(context.Background()), Equals, "")
+}
+
+func (s *SecureSuite) TestSecureEmptyConfigSetsNoHeaders(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Secure(SecureConfig{})).GET("/", finalHandler)
+
+	response := doTestRequest(rg, "GET", "/")
+
+	for name := range response.Header() {
+		c.Check(strings.HasPrefix(name, "Content-Security"), Equals, false)
+		c.Check(name, Not(Equals), "X-Frame-Options")
+	}
+}
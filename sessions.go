@@ -0,0 +1,502 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type sessionKey int
+
+const sessionContextKey sessionKey = iota
+
+const flashKey = "_flash"
+
+// maxCookieSize is the max size of a cookie value accepted by browsers
+const maxCookieSize = 4096
+
+var (
+	// ErrSessionCookieTooLarge is returned by the cookie store when the encoded session exceeds the cookie size limit
+	ErrSessionCookieTooLarge = errors.New("session cookie too large")
+
+	// ErrSessionInvalid is returned by the cookie store when the cookie can not be decrypted
+	ErrSessionInvalid = errors.New("session invalid")
+)
+
+func init() {
+	// flashes are stored as a list in the session values
+	gob.Register([]interface{}(nil))
+}
+
+// SessionData is the session state persisted by a SessionStore.
+// Values stored in a session must be registered with gob.Register when
+// the store serializes them, basic types are registered by default.
+type SessionData struct {
+	ID         string
+	Values     map[string]interface{}
+	CreatedAt  time.Time
+	LastAccess time.Time
+
+	// ExpiresAt is the time the session expires because of the idle or absolute timeout
+	ExpiresAt time.Time
+}
+
+// SessionStore persists sessions, the cookie value is owned by the store.
+// Server side stores use the session id as cookie value, the cookie store
+// uses the encrypted session data.
+type SessionStore interface {
+	// Load returns the session for the cookie value, ok is false for unknown sessions
+	Load(ctx context.Context, cookie string) (data *SessionData, ok bool, err error)
+
+	// Save persists the session and returns the cookie value
+	Save(ctx context.Context, data *SessionData) (cookie string, err error)
+
+	// Delete removes the session
+	Delete(ctx context.Context, data *SessionData) error
+}
+
+// SessionState is the session of the current request, retrieve it using Session(ctx).
+// The session is only saved when it is modified.
+type SessionState struct {
+	mu   sync.Mutex
+	data *SessionData
+
+	isNew     bool
+	modified  bool
+	renew     bool
+	destroyed bool
+}
+
+// ID returns the session id
+func (session *SessionState) ID() string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	return session.data.ID
+}
+
+// IsNew reports if the session is created by this request
+func (session *SessionState) IsNew() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	return session.isNew
+}
+
+// Get returns the value stored under key, nil when not present
+func (session *SessionState) Get(key string) interface{} {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	return session.data.Values[key]
+}
+
+// Set stores the value under key
+func (session *SessionState) Set(key string, value interface{}) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.data.Values[key] = value
+	session.modified = true
+}
+
+// Delete removes the value stored under key
+func (session *SessionState) Delete(key string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if _, ok := session.data.Values[key]; ok {
+		delete(session.data.Values, key)
+		session.modified = true
+	}
+}
+
+// Clear removes all values from the session
+func (session *SessionState) Clear() {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.data.Values = make(map[string]interface{})
+	session.modified = true
+}
+
+// RenewID gives the session a new id while keeping the values, the old session is removed.
+// Call it after login or any other privilege change to prevent session fixation.
+func (session *SessionState) RenewID() {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.renew = true
+	session.modified = true
+}
+
+// Destroy removes the session from the store and expires the cookie
+func (session *SessionState) Destroy() {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.destroyed = true
+}
+
+// AddFlash adds a flash message, flash messages are removed when read by Flashes
+func (session *SessionState) AddFlash(value interface{}) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	flashes, _ := session.data.Values[flashKey].([]interface{})
+	session.data.Values[flashKey] = append(flashes, value)
+	session.modified = true
+}
+
+// Flashes returns and removes the flash messages
+func (session *SessionState) Flashes() []interface{} {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	flashes, ok := session.data.Values[flashKey].([]interface{})
+	if !ok {
+		return nil
+	}
+	delete(session.data.Values, flashKey)
+	session.modified = true
+	return flashes
+}
+
+// Session retrieves the session of the request from the context,
+// nil is returned when the Sessions middleware is not used
+func Session(ctx context.Context) *SessionState {
+	if ctx == nil {
+		return nil
+	}
+
+	if session, ok := ctx.Value(sessionContextKey).(*SessionState); ok {
+		return session
+	}
+
+	return nil
+}
+
+// SessionConfig configures the Sessions middleware
+type SessionConfig struct {
+	Store SessionStore
+
+	CookieName   string
+	CookiePath   string
+	CookieDomain string
+	CookieSecure bool
+	SameSite     http.SameSite
+
+	// IdleTimeout expires the session when it is not used, 0 disables the idle timeout
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout expires the session after it is created, 0 disables the absolute timeout
+	AbsoluteTimeout time.Duration
+
+	// ErrorHandler is called when the store fails to load or save a session
+	ErrorHandler func(ctx context.Context, err error)
+}
+
+// DefaultSessionConfig returns a config with a 30 minute idle timeout and a 24 hour absolute timeout,
+// the cookie is only sent over secure connections when Env() is not development.
+func DefaultSessionConfig(store SessionStore) SessionConfig {
+	return SessionConfig{
+		Store:           store,
+		CookieName:      "session",
+		CookiePath:      "/",
+		CookieSecure:    Env() != Development,
+		SameSite:        http.SameSiteLaxMode,
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+	}
+}
+
+// Sessions is a middleware that loads the session of the request, retrieve it using Session(ctx).
+// A modified session is saved right before the response headers are written.
+// The last access time used by the idle timeout is refreshed at most every quarter of the idle timeout.
+func Sessions(config SessionConfig) Middleware {
+	if config.Store == nil {
+		panic("sessions require a store")
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			session := loadSession(ctx, config, req)
+			ctx = context.WithValue(ctx, sessionContextKey, session)
+
+			responseWriter, ok := rw.(ResponseWriter)
+			if !ok {
+				responseWriter = newResponseWriter(rw)
+			}
+
+			var once sync.Once
+			save := func() {
+				once.Do(func() {
+					saveSession(ctx, config, responseWriter, session)
+				})
+			}
+			responseWriter.Before(func(ResponseWriter) {
+				save()
+			})
+
+			next(ctx, responseWriter, req)
+			save()
+		}
+	}
+}
+
+func loadSession(ctx context.Context, config SessionConfig, req *http.Request) *SessionState {
+	now := time.Now()
+
+	if cookie, err := req.Cookie(config.CookieName); err == nil {
+		data, ok, err := config.Store.Load(ctx, cookie.Value)
+		if err != nil && config.ErrorHandler != nil {
+			config.ErrorHandler(ctx, err)
+		}
+
+		if ok && err == nil {
+			if data.ExpiresAt.IsZero() || now.Before(data.ExpiresAt) {
+				if data.Values == nil {
+					data.Values = make(map[string]interface{})
+				}
+				session := &SessionState{data: data}
+				if config.IdleTimeout > 0 && now.Sub(data.LastAccess) > config.IdleTimeout/4 {
+					session.modified = true
+				}
+				return session
+			}
+			config.Store.Delete(ctx, data)
+		}
+	}
+
+	return &SessionState{
+		isNew: true,
+		data: &SessionData{
+			ID:         generateSessionID(),
+			Values:     make(map[string]interface{}),
+			CreatedAt:  now,
+			LastAccess: now,
+		},
+	}
+}
+
+func saveSession(ctx context.Context, config SessionConfig, rw http.ResponseWriter, session *SessionState) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	cookie := &http.Cookie{
+		Name:     config.CookieName,
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		Secure:   config.CookieSecure,
+		HttpOnly: true,
+		SameSite: config.SameSite,
+	}
+
+	if session.destroyed {
+		if !session.isNew {
+			if err := config.Store.Delete(ctx, session.data); err != nil && config.ErrorHandler != nil {
+				config.ErrorHandler(ctx, err)
+			}
+			cookie.MaxAge = -1
+			http.SetCookie(rw, cookie)
+		}
+		return
+	}
+
+	if !session.modified {
+		return
+	}
+
+	if session.renew && !session.isNew {
+		if err := config.Store.Delete(ctx, session.data); err != nil && config.ErrorHandler != nil {
+			config.ErrorHandler(ctx, err)
+		}
+		session.data.ID = generateSessionID()
+	}
+
+	now := time.Now()
+	data := session.data
+	data.LastAccess = now
+	data.ExpiresAt = time.Time{}
+	if config.IdleTimeout > 0 {
+		data.ExpiresAt = now.Add(config.IdleTimeout)
+	}
+	if config.AbsoluteTimeout > 0 {
+		absolute := data.CreatedAt.Add(config.AbsoluteTimeout)
+		if data.ExpiresAt.IsZero() || absolute.Before(data.ExpiresAt) {
+			data.ExpiresAt = absolute
+		}
+	}
+
+	value, err := config.Store.Save(ctx, data)
+	if err != nil {
+		if config.ErrorHandler != nil {
+			config.ErrorHandler(ctx, err)
+		}
+		return
+	}
+
+	cookie.Value = value
+	if !data.ExpiresAt.IsZero() {
+		cookie.MaxAge = ceilSeconds(data.ExpiresAt.Sub(now))
+	}
+	http.SetCookie(rw, cookie)
+	session.modified = false
+	session.renew = false
+}
+
+func generateSessionID() string {
+	var buf [32]byte
+	rand.Read(buf[:])
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]SessionData
+	saves    int
+}
+
+// NewMemorySessionStore creates a server side store keeping the sessions in memory,
+// expired sessions are evicted while saving
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{
+		sessions: make(map[string]SessionData),
+	}
+}
+
+func (store *memorySessionStore) Load(_ context.Context, cookie string) (*SessionData, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	data, ok := store.sessions[cookie]
+	if !ok {
+		return nil, false, nil
+	}
+	if !data.ExpiresAt.IsZero() && time.Now().After(data.ExpiresAt) {
+		delete(store.sessions, cookie)
+		return nil, false, nil
+	}
+
+	data.Values = copySessionValues(data.Values)
+	return &data, true, nil
+}
+
+func (store *memorySessionStore) Save(_ context.Context, data *SessionData) (string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.saves++
+	if store.saves%1024 == 0 {
+		now := time.Now()
+		for id, session := range store.sessions {
+			if !session.ExpiresAt.IsZero() && now.After(session.ExpiresAt) {
+				delete(store.sessions, id)
+			}
+		}
+	}
+
+	stored := *data
+	stored.Values = copySessionValues(data.Values)
+	store.sessions[data.ID] = stored
+	return data.ID, nil
+}
+
+func (store *memorySessionStore) Delete(_ context.Context, data *SessionData) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.sessions, data.ID)
+	return nil
+}
+
+func copySessionValues(values map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return copied
+}
+
+type cookieSessionStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewCookieSessionStore creates a store keeping the session in the cookie itself.
+// The session is encrypted and authenticated with AES-GCM, keys must be 16, 24 or 32 bytes.
+// The first key is used to encrypt, all keys are tried to decrypt which allows key rotation.
+func NewCookieSessionStore(keys ...[]byte) (SessionStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("cookie session store requires a key")
+	}
+
+	store := &cookieSessionStore{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		store.aeads = append(store.aeads, aead)
+	}
+	return store, nil
+}
+
+func (store *cookieSessionStore) Load(_ context.Context, cookie string) (*SessionData, bool, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return nil, false, ErrSessionInvalid
+	}
+
+	for _, aead := range store.aeads {
+		size := aead.NonceSize()
+		if len(sealed) < size {
+			break
+		}
+
+		plain, err := aead.Open(nil, sealed[:size], sealed[size:], nil)
+		if err != nil {
+			continue
+		}
+
+		data := &SessionData{}
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(data); err != nil {
+			return nil, false, err
+		}
+		return data, true, nil
+	}
+	return nil, false, ErrSessionInvalid
+}
+
+func (store *cookieSessionStore) Save(_ context.Context, data *SessionData) (string, error) {
+	var plain bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(data); err != nil {
+		return "", err
+	}
+
+	aead := store.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain.Bytes(), nil))
+	if len(value) > maxCookieSize {
+		return "", ErrSessionCookieTooLarge
+	}
+	return value, nil
+}
+
+// Delete is a no-op, the session is removed by expiring the cookie
+func (store *cookieSessionStore) Delete(context.Context, *SessionData) error {
+	return nil
+}
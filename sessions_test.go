@@ -0,0 +1,288 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+type SessionsSuite struct{}
+
+var _ = Suite(&SessionsSuite{})
+
+func sessionCookie(response *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == "session" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// withCookie returns the request headers sending the cookie, none for a nil cookie
+func withCookie(cookie *http.Cookie) map[string]string {
+	if cookie == nil {
+		return nil
+	}
+	return map[string]string{"Cookie": cookie.Name + "=" + cookie.Value}
+}
+
+func sessionSetHandler(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	Session(ctx).Set("user", "john")
+	rw.Write([]byte("set"))
+}
+
+func sessionGetHandler(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	user, _ := Session(ctx).Get("user").(string)
+	rw.Write([]byte(user))
+}
+
+func (s *SessionsSuite) stores(c *C) map[string]SessionStore {
+	cookieStore, err := NewCookieSessionStore([]byte(strings.Repeat("k", 32)))
+	c.Assert(err, IsNil)
+
+	return map[string]SessionStore{
+		"memory": NewMemorySessionStore(),
+		"cookie": cookieStore,
+	}
+}
+
+func (s *SessionsSuite) TestSessions(c *C) {
+	// store is the store of the stores the tests run with
+	var store SessionStore
+
+	tests := []struct {
+		setup    []string // requested first, the session cookie is sent with the next request
+		wait     time.Duration
+		path     string
+		response string
+		cookie   bool
+		maxAge   int
+		prepare  func(RouteGroup)
+	}{
+		{
+			// sessions are only saved when modified
+			path: "/get",
+			prepare: func(rg RouteGroup) {
+				rg.With(Sessions(DefaultSessionConfig(store))).GET("/get", sessionGetHandler)
+			},
+		}, {
+			path:     "/set",
+			response: "set",
+			cookie:   true,
+			maxAge:   1800,
+			prepare: func(rg RouteGroup) {
+				rg.With(Sessions(DefaultSessionConfig(store))).GET("/set", sessionSetHandler)
+			},
+		}, {
+			setup:    []string{"/set"},
+			path:     "/get",
+			response: "john",
+			prepare: func(rg RouteGroup) {
+				sessions := rg.With(Sessions(DefaultSessionConfig(store)))
+				sessions.GET("/set", sessionSetHandler)
+				sessions.GET("/get", sessionGetHandler)
+			},
+		}, {
+			// destroy expires the cookie
+			setup:  []string{"/set"},
+			path:   "/logout",
+			cookie: true,
+			maxAge: -1,
+			prepare: func(rg RouteGroup) {
+				sessions := rg.With(Sessions(DefaultSessionConfig(store)))
+				sessions.GET("/set", sessionSetHandler)
+				sessions.GET("/logout", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+					Session(ctx).Destroy()
+				})
+			},
+		}, {
+			// flashes are read once
+			setup:    []string{"/flash"},
+			path:     "/flashes",
+			response: "saved",
+			cookie:   true,
+			prepare: func(rg RouteGroup) {
+				sessions := rg.With(Sessions(DefaultSessionConfig(store)))
+				sessions.GET("/flash", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+					Session(ctx).AddFlash("saved")
+				})
+				sessions.GET("/flashes", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+					for _, flash := range Session(ctx).Flashes() {
+						rw.Write([]byte(flash.(string)))
+					}
+				})
+			},
+		}, {
+			setup: []string{"/flash", "/flashes"},
+			path:  "/flashes",
+			prepare: func(rg RouteGroup) {
+				sessions := rg.With(Sessions(DefaultSessionConfig(store)))
+				sessions.GET("/flash", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+					Session(ctx).AddFlash("saved")
+				})
+				sessions.GET("/flashes", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+					for _, flash := range Session(ctx).Flashes() {
+						rw.Write([]byte(flash.(string)))
+					}
+				})
+			},
+		}, {
+			// expired after the absolute timeout
+			setup: []string{"/set"},
+			wait:  5 * time.Millisecond,
+			path:  "/get",
+			prepare: func(rg RouteGroup) {
+				config := DefaultSessionConfig(store)
+				config.AbsoluteTimeout = time.Millisecond
+				sessions := rg.With(Sessions(config))
+				sessions.GET("/set", sessionSetHandler)
+				sessions.GET("/get", sessionGetHandler)
+			},
+		}, {
+			// idle sessions are touched
+			setup:    []string{"/set"},
+			wait:     15 * time.Millisecond,
+			path:     "/get",
+			response: "john",
+			cookie:   true,
+			prepare: func(rg RouteGroup) {
+				config := DefaultSessionConfig(store)
+				config.IdleTimeout = 40 * time.Millisecond
+				sessions := rg.With(Sessions(config))
+				sessions.GET("/set", sessionSetHandler)
+				sessions.GET("/get", sessionGetHandler)
+			},
+		},
+	}
+
+	for name, candidate := range s.stores(c) {
+		store = candidate
+		for index, test := range tests {
+			comment := Commentf("test %d failed with the %s store", index, name)
+			rg := newRouteGroup(httprouter.New())
+			test.prepare(rg)
+
+			var cookie *http.Cookie
+			for _, path := range test.setup {
+				if set := sessionCookie(doTestRequest(rg, "GET", path, withCookie(cookie))); set != nil {
+					cookie = set
+				}
+			}
+			time.Sleep(test.wait)
+
+			response := doTestRequest(rg, "GET", test.path, withCookie(cookie))
+
+			c.Check(response.Body.String(), Equals, test.response, comment)
+			set := sessionCookie(response)
+			if c.Check(set != nil, Equals, test.cookie, comment) && set != nil {
+				c.Check(set.HttpOnly, Equals, true, comment)
+				if test.maxAge != 0 {
+					c.Check(set.MaxAge, Equals, test.maxAge, comment)
+				}
+			}
+		}
+	}
+}
+
+func (s *SessionsSuite) TestSessionIsSavedBeforeHeadersAreWritten(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.With(Sessions(DefaultSessionConfig(NewMemorySessionStore()))).GET("/", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		Session(ctx).Set("a", 1)
+		rw.WriteHeader(http.StatusCreated)
+		Session(ctx).Set("b", 2)
+	})
+
+	response := doTestRequest(rg, "GET", "/")
+
+	c.Assert(response.Code, Equals, 201)
+	c.Assert(sessionCookie(response), NotNil)
+}
+
+func (s *SessionsSuite) TestRenewID(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	sessions := rg.With(Sessions(DefaultSessionConfig(NewMemorySessionStore())))
+	sessions.GET("/set", sessionSetHandler)
+	sessions.GET("/get", sessionGetHandler)
+	sessions.GET("/login", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		Session(ctx).RenewID()
+	})
+
+	cookie := sessionCookie(doTestRequest(rg, "GET", "/set"))
+	renewed := sessionCookie(doTestRequest(rg, "GET", "/login", withCookie(cookie)))
+
+	c.Assert(renewed, NotNil)
+	c.Assert(renewed.Value, Not(Equals), cookie.Value)
+	c.Assert(doTestRequest(rg, "GET", "/get", withCookie(renewed)).Body.String(), Equals, "john")
+	c.Assert(doTestRequest(rg, "GET", "/get", withCookie(cookie)).Body.String(), Equals, "")
+}
+
+func (s *SessionsSuite) TestDestroyDeletesStoredSession(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	sessions := rg.With(Sessions(DefaultSessionConfig(NewMemorySessionStore())))
+	sessions.GET("/set", sessionSetHandler)
+	sessions.GET("/get", sessionGetHandler)
+	sessions.GET("/logout", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		Session(ctx).Destroy()
+	})
+
+	cookie := sessionCookie(doTestRequest(rg, "GET", "/set"))
+	doTestRequest(rg, "GET", "/logout", withCookie(cookie))
+
+	c.Assert(doTestRequest(rg, "GET", "/get", withCookie(cookie)).Body.String(), Equals, "")
+}
+
+func (s *SessionsSuite) TestCookieStoreRejectsTamperedCookie(c *C) {
+	store, _ := NewCookieSessionStore([]byte(strings.Repeat("k", 32)))
+	var loadErr error
+	config := DefaultSessionConfig(store)
+	config.ErrorHandler = func(ctx context.Context, err error) {
+		loadErr = err
+	}
+	rg := newRouteGroup(httprouter.New())
+	sessions := rg.With(Sessions(config))
+	sessions.GET("/set", sessionSetHandler)
+	sessions.GET("/get", sessionGetHandler)
+
+	cookie := sessionCookie(doTestRequest(rg, "GET", "/set"))
+	tampered := "A"
+	if cookie.Value[0] == 'A' {
+		tampered = "B"
+	}
+	cookie.Value = tampered + cookie.Value[1:]
+
+	c.Assert(doTestRequest(rg, "GET", "/get", withCookie(cookie)).Body.String(), Equals, "")
+	c.Assert(loadErr, Equals, ErrSessionInvalid)
+}
+
+func (s *SessionsSuite) TestCookieStoreKeyRotation(c *C) {
+	oldKey := []byte(strings.Repeat("o", 16))
+	newKey := []byte(strings.Repeat("n", 16))
+	oldStore, _ := NewCookieSessionStore(oldKey)
+	rotatedStore, _ := NewCookieSessionStore(newKey, oldKey)
+
+	value, err := oldStore.Save(context.Background(), &SessionData{ID: "1", Values: map[string]interface{}{"a": "b"}})
+	c.Assert(err, IsNil)
+
+	data, ok, err := rotatedStore.Load(context.Background(), value)
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(data.Values["a"], Equals, "b")
+}
+
+func (s *SessionsSuite) TestCookieStoreInvalidKey(c *C) {
+	_, err := NewCookieSessionStore([]byte("short"))
+	c.Assert(err, NotNil)
+
+	_, err = NewCookieSessionStore()
+	c.Assert(err, ErrorMatches, "cookie session store requires a key")
+}
+
+func (s *SessionsSuite) TestSessionWithoutMiddleware(c *C) {
+	c.Assert(Session(nil), IsNil)
+	c.Assert(Session(context.Background()), IsNil)
+}
@@ -0,0 +1,174 @@
+package webapp
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type spaMount struct {
+	host     string
+	path     string
+	excluded []string
+	handler  ContextHandler
+}
+
+// SPAExclude keeps the requests below the paths away from the single page application,
+// e.g. for API groups, misses there go to the App's NotFound handler. The paths are
+// absolute request paths.
+//
+//     app.SPA("/", frontend, "index.html", webapp.SPAExclude("/api"))
+func SPAExclude(paths ...string) StaticOption {
+	return func(config *staticConfig) {
+		for _, excluded := range paths {
+			config.spaExcluded = append(config.spaExcluded, strings.TrimSuffix(path.Clean("/"+excluded), "/"))
+		}
+	}
+}
+
+// SPA serves a single page application from fsys. Existing files are served as with
+// StaticFS, unknown paths without a file extension requested by a browser, with
+// text/html in the Accept header, are answered with the index file so the client side
+// router can handle them. Other misses, e.g. from API clients, go to the App's NotFound handler.
+//
+// Routes registered on the App take precedence, the application is
+// served for the requests no other route matches. Browsers requesting a path
+// only having routes for other methods also get the application instead of a 405.
+// Use SPAExclude to keep the misses of API groups away from the application.
+// The application is listed by Routes for GET and HEAD.
+//
+//     app.Group("/api").GET("/users", users)
+//     app.SPA("/", frontend, "index.html", webapp.SPAExclude("/api"))
+func (group *routeGroup) SPA(relativePath string, fsys fs.FS, index string, options ...StaticOption) {
+	if group.app == nil {
+		panic("spa requires a route group of an app")
+	}
+
+	absolutePath := group.calculateAbsolutePath(relativePath)
+	config := newStaticConfig(options)
+	spaHandler := group.createSPAHandler(http.FS(fsys), path.Clean("/"+index), config)
+	routePath := path.Join(absolutePath, "/*filepath")
+
+	entry := group.newEntry("GET", routePath, func(bound *routeGroup) error {
+		route := &RouteInfo{
+			Method:      "GET",
+			Host:        bound.host,
+			Path:        routePath,
+			Handler:     handlerName(spaHandler),
+			Middlewares: len(bound.middleware),
+
+			Requirements: bound.requirements,
+			Metadata:     bound.metadata,
+		}
+		handler := bound.middleware.Then(spaHandler)
+		handler = withRoute(route, handler)
+		bound.routes.add(route)
+
+		head := *route
+		head.Method = "HEAD"
+		bound.routes.add(&head)
+
+		bound.routes.addSPA(&spaMount{
+			host:     bound.host,
+			path:     strings.TrimSuffix(absolutePath, "/"),
+			excluded: config.spaExcluded,
+			handler:  handler,
+		})
+		return nil
+	})
+	if err := group.app.addEntry(entry); err != nil {
+		panic(err)
+	}
+}
+
+func (group *routeGroup) createSPAHandler(fileSystem http.FileSystem, index string, config staticConfig) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		name := path.Clean("/" + Param(ctx, "filepath"))
+		if !config.hideDotfiles || !hasDotfile(name) {
+			if file, stat, ok := openStatic(fileSystem, name); ok {
+				defer file.Close()
+				if !stat.IsDir() {
+					serveStaticFile(rw, req, fileSystem, name, file, stat, config)
+					return
+				}
+			}
+		}
+
+		if path.Ext(name) != "" || !acceptsValue(req.Header.Values("Accept"), "text/html", "") {
+			group.notFound(ctx, rw, req)
+			return
+		}
+
+		if _, ok := config.cacheControl[""]; !ok {
+			rw.Header().Set("Cache-Control", "no-cache")
+		}
+		group.serveFile(ctx, rw, req, fileSystem, index, config)
+	}
+}
+
+// serveSPA lets the single page application mounted on the longest
+// matching path handle the request, it returns false when none matches
+func (app *webapp) serveSPA(rw http.ResponseWriter, req *http.Request) bool {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+
+	routes := app.current().routes
+	routes.RLock()
+	mounts := routes.spa
+	routes.RUnlock()
+
+	host := matchedHost(req)
+	for _, mount := range mounts {
+		if mount.host != host {
+			continue
+		}
+		if !hasPathPrefix(req.URL.Path, mount.path) || mount.isExcluded(req.URL.Path) {
+			continue
+		}
+
+		params := RouteParams{{Key: "filepath", Value: strings.TrimPrefix(req.URL.Path, mount.path)}}
+		ctx := newContextWithParams(context.Background(), withHostParams(req, params).httprouterParams())
+		mount.handler(ctx, newResponseWriter(rw), req)
+		return true
+	}
+	return false
+}
+
+// serveSPAPage lets the single page application handle the requests of browsers for pages
+// that are not a file, it returns false for other requests or when no application matches
+func (app *webapp) serveSPAPage(rw http.ResponseWriter, req *http.Request) bool {
+	if path.Ext(req.URL.Path) != "" || !acceptsValue(req.Header.Values("Accept"), "text/html", "") {
+		return false
+	}
+	return app.serveSPA(rw, req)
+}
+
+// addSPA adds the mount keeping the longest paths first
+func (table *routeTable) addSPA(mount *spaMount) {
+	table.Lock()
+	defer table.Unlock()
+
+	i := 0
+	for i < len(table.spa) && len(table.spa[i].path) >= len(mount.path) {
+		i++
+	}
+	spa := append(table.spa[:i:i], mount)
+	table.spa = append(spa, table.spa[i:]...)
+}
+
+func (mount *spaMount) isExcluded(requestPath string) bool {
+	for _, excluded := range mount.excluded {
+		if hasPathPrefix(requestPath, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPathPrefix reports if the path is the prefix or below it, prefixes have no trailing slash
+func hasPathPrefix(requestPath, prefix string) bool {
+	return prefix == "" || requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
@@ -0,0 +1,297 @@
+package webapp
+
+import (
+	"context"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"testing/fstest"
+)
+
+type SPASuite struct{}
+
+var _ = Suite(&SPASuite{})
+
+var spaFS = fstest.MapFS{
+	"index.html":    {Data: []byte("spa")},
+	"static/app.js": {Data: []byte("app()")},
+}
+
+func (s *SPASuite) TestSPA(c *C) {
+	browser := "text/html,application/xhtml+xml,*/*;q=0.8"
+	tests := []struct {
+		method       string
+		path         string
+		accept       string
+		code         int
+		response     string
+		cacheControl string
+		prepare      func(RouteGroup)
+	}{
+		{
+			// files are served
+			method:   "GET",
+			path:     "/static/app.js",
+			accept:   "*/*",
+			code:     200,
+			response: "app()",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			// routes take precedence
+			method:   "GET",
+			path:     "/api/users",
+			accept:   "text/html",
+			code:     200,
+			response: "users",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			// browsers get the index for unknown paths
+			method:       "GET",
+			path:         "/",
+			accept:       browser,
+			code:         200,
+			response:     "spa",
+			cacheControl: "no-cache",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:       "GET",
+			path:         "/users/1",
+			accept:       browser,
+			code:         200,
+			response:     "spa",
+			cacheControl: "no-cache",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:       "GET",
+			path:         "/static",
+			accept:       browser,
+			code:         200,
+			response:     "spa",
+			cacheControl: "no-cache",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			// other misses are not found
+			method:   "GET",
+			path:     "/api/unknown",
+			accept:   "application/json",
+			code:     404,
+			response: "not found",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/users/1",
+			accept:   "*/*",
+			code:     404,
+			response: "not found",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/users/1",
+			accept:   "text/html;q=0",
+			code:     404,
+			response: "not found",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/static/missing.js",
+			accept:   browser,
+			code:     404,
+			response: "not found",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			// excluded paths
+			method:   "GET",
+			path:     "/api/users",
+			accept:   browser,
+			code:     200,
+			response: "users",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html", SPAExclude("/api/"))
+			},
+		}, {
+			method:   "GET",
+			path:     "/api",
+			accept:   browser,
+			code:     404,
+			response: "not found",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html", SPAExclude("/api/"))
+			},
+		}, {
+			method:   "GET",
+			path:     "/api/typo",
+			accept:   browser,
+			code:     404,
+			response: "not found",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html", SPAExclude("/api/"))
+			},
+		}, {
+			method:   "GET",
+			path:     "/apis",
+			accept:   browser,
+			code:     200,
+			response: "spa",
+			prepare: func(rg RouteGroup) {
+				rg.Group("/api").GET("/users", writeHandler("users"))
+				rg.SPA("/", spaFS, "index.html", SPAExclude("/api/"))
+			},
+		}, {
+			// the application mounted on the longest path
+			method:   "GET",
+			path:     "/admin/settings",
+			accept:   "text/html",
+			code:     200,
+			response: "admin",
+			prepare: func(rg RouteGroup) {
+				rg.SPA("/admin", fstest.MapFS{"index.html": {Data: []byte("admin")}}, "index.html")
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/administration",
+			accept:   "text/html",
+			code:     200,
+			response: "spa",
+			prepare: func(rg RouteGroup) {
+				rg.SPA("/admin", fstest.MapFS{"index.html": {Data: []byte("admin")}}, "index.html")
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/other",
+			accept:   "text/html",
+			code:     200,
+			response: "spa",
+			prepare: func(rg RouteGroup) {
+				rg.SPA("/admin", fstest.MapFS{"index.html": {Data: []byte("admin")}}, "index.html")
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			// browsers get the index for paths with routes for other methods
+			method:   "GET",
+			path:     "/contact",
+			accept:   browser,
+			code:     200,
+			response: "spa",
+			prepare: func(rg RouteGroup) {
+				rg.POST("/contact", writeHandler("sent"))
+				rg.POST("/contact.json", writeHandler("sent"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "HEAD",
+			path:     "/contact",
+			accept:   browser,
+			code:     200,
+			response: "",
+			prepare: func(rg RouteGroup) {
+				rg.POST("/contact", writeHandler("sent"))
+				rg.POST("/contact.json", writeHandler("sent"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/contact",
+			accept:   "application/json",
+			code:     405,
+			response: "Method Not Allowed\n",
+			prepare: func(rg RouteGroup) {
+				rg.POST("/contact", writeHandler("sent"))
+				rg.POST("/contact.json", writeHandler("sent"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "GET",
+			path:     "/contact.json",
+			accept:   browser,
+			code:     405,
+			response: "Method Not Allowed\n",
+			prepare: func(rg RouteGroup) {
+				rg.POST("/contact", writeHandler("sent"))
+				rg.POST("/contact.json", writeHandler("sent"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "PUT",
+			path:     "/contact",
+			accept:   browser,
+			code:     405,
+			response: "Method Not Allowed\n",
+			prepare: func(rg RouteGroup) {
+				rg.POST("/contact", writeHandler("sent"))
+				rg.POST("/contact.json", writeHandler("sent"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		}, {
+			method:   "POST",
+			path:     "/contact",
+			accept:   browser,
+			code:     200,
+			response: "sent",
+			prepare: func(rg RouteGroup) {
+				rg.POST("/contact", writeHandler("sent"))
+				rg.POST("/contact.json", writeHandler("sent"))
+				rg.SPA("/", spaFS, "index.html")
+			},
+		},
+	}
+
+	for index, test := range tests {
+		app := New()
+		app.NotFound(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte("not found"))
+		})
+		test.prepare(app)
+
+		response := doTestRequest(app, test.method, test.path, map[string]string{"Accept": test.accept})
+
+		c.Check(response.Code, Equals, test.code, Commentf("test %d failed", index))
+		c.Check(response.Body.String(), Equals, test.response, Commentf("test %d failed", index))
+		if test.cacheControl != "" {
+			c.Check(response.Header().Get("Cache-Control"), Equals, test.cacheControl, Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *SPASuite) TestSPAIsListedInRoutes(c *C) {
+	app := New()
+	app.SPA("/", spaFS, "index.html")
+
+	routes := app.Routes()
+	c.Assert(routes, HasLen, 2)
+	c.Assert(routes[0].Method, Equals, "GET")
+	c.Assert(routes[0].Path, Equals, "/*filepath")
+	c.Assert(routes[1].Method, Equals, "HEAD")
+	c.Assert(routes[1].Path, Equals, "/*filepath")
+}
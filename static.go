@@ -0,0 +1,340 @@
+package webapp
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StaticOption configures the static file handlers
+type StaticOption func(config *staticConfig)
+
+type staticConfig struct {
+	notFound      bool
+	noListing     bool
+	indexes       []string
+	cacheControl  map[string]string
+	precompressed bool
+	hideDotfiles  bool
+
+	// spaExcluded are the paths excluded from the single page application
+	spaExcluded []string
+}
+
+func newStaticConfig(options []StaticOption) staticConfig {
+	config := staticConfig{
+		indexes:      []string{"index.html"},
+		cacheControl: make(map[string]string),
+	}
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
+
+// StaticNotFound routes requests for missing files through the App's NotFound
+// handler instead of the plain 404 of http.FileServer
+func StaticNotFound() StaticOption {
+	return func(config *staticConfig) {
+		config.notFound = true
+	}
+}
+
+// StaticNoListing disables the directory listing, directories without
+// an index file are handled as missing files
+func StaticNoListing() StaticOption {
+	return func(config *staticConfig) {
+		config.noListing = true
+	}
+}
+
+// StaticIndex sets the files served for a directory in order of preference,
+// defaults to index.html. Without names no index files are served.
+func StaticIndex(names ...string) StaticOption {
+	return func(config *staticConfig) {
+		config.indexes = names
+	}
+}
+
+// StaticCacheControl sets the Cache-Control header for files with one of the extensions,
+// without extensions the header is set for all files without a more specific value.
+//     router.StaticFS("/assets", assets,
+//         webapp.StaticCacheControl("no-cache"),
+//         webapp.StaticCacheControl("public, max-age=86400", ".css", ".js"),
+//     )
+func StaticCacheControl(value string, extensions ...string) StaticOption {
+	return func(config *staticConfig) {
+		if len(extensions) == 0 {
+			config.cacheControl[""] = value
+		}
+		for _, extension := range extensions {
+			config.cacheControl[strings.ToLower(extension)] = value
+		}
+	}
+}
+
+// StaticPrecompressed serves the .br or .gz sibling of a file when
+// it exists and the client accepts the encoding
+func StaticPrecompressed() StaticOption {
+	return func(config *staticConfig) {
+		config.precompressed = true
+	}
+}
+
+// StaticHideDotfiles handles files and directories starting with a dot as missing files
+func StaticHideDotfiles() StaticOption {
+	return func(config *staticConfig) {
+		config.hideDotfiles = true
+	}
+}
+
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (group *routeGroup) createStaticHandler(fileSystem http.FileSystem, config staticConfig) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		name := path.Clean("/" + Param(ctx, "filepath"))
+		if config.hideDotfiles && hasDotfile(name) {
+			group.staticMiss(ctx, rw, req, config)
+			return
+		}
+
+		file, stat, ok := openStatic(fileSystem, name)
+		if !ok {
+			group.staticMiss(ctx, rw, req, config)
+			return
+		}
+		defer file.Close()
+
+		if !stat.IsDir() {
+			serveStaticFile(rw, req, fileSystem, name, file, stat, config)
+			return
+		}
+
+		if !strings.HasSuffix(req.URL.Path, "/") {
+			target := path.Base(req.URL.Path) + "/"
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			http.Redirect(rw, req, target, http.StatusMovedPermanently)
+			return
+		}
+
+		for _, index := range config.indexes {
+			indexName := path.Join(name, index)
+			indexFile, indexStat, ok := openStatic(fileSystem, indexName)
+			if !ok {
+				continue
+			}
+			defer indexFile.Close()
+
+			if !indexStat.IsDir() {
+				serveStaticFile(rw, req, fileSystem, indexName, indexFile, indexStat, config)
+				return
+			}
+		}
+
+		if config.noListing {
+			group.staticMiss(ctx, rw, req, config)
+			return
+		}
+		serveDirList(rw, req, file, config)
+	}
+}
+
+// serveDirList writes the listing of the directory, hidden dotfiles are left out
+func serveDirList(rw http.ResponseWriter, req *http.Request, dir http.File, config staticConfig) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		http.Error(rw, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if req.Method == http.MethodHead {
+		return
+	}
+
+	var listing strings.Builder
+	listing.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		name := entry.Name()
+		if config.hideDotfiles && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: name}
+		fmt.Fprintf(&listing, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(name))
+	}
+	listing.WriteString("</pre>\n")
+	io.WriteString(rw, listing.String())
+}
+
+func (group *routeGroup) createStaticFileHandler(fileSystem http.FileSystem, name string, config staticConfig) ContextHandler {
+	name = path.Clean("/" + name)
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		group.serveFile(ctx, rw, req, fileSystem, name, config)
+	}
+}
+
+// serveFile serves a single file, directories are handled as missing files
+func (group *routeGroup) serveFile(ctx context.Context, rw http.ResponseWriter, req *http.Request, fileSystem http.FileSystem, name string, config staticConfig) {
+	file, stat, ok := openStatic(fileSystem, name)
+	if !ok {
+		group.staticMiss(ctx, rw, req, config)
+		return
+	}
+	defer file.Close()
+
+	if stat.IsDir() {
+		group.staticMiss(ctx, rw, req, config)
+		return
+	}
+	serveStaticFile(rw, req, fileSystem, name, file, stat, config)
+}
+
+func serveStaticFile(rw http.ResponseWriter, req *http.Request, fileSystem http.FileSystem, name string, file http.File, stat fs.FileInfo, config staticConfig) {
+	header := rw.Header()
+	if value, ok := config.cacheControl[strings.ToLower(path.Ext(name))]; ok {
+		header.Set("Cache-Control", value)
+	} else if value, ok := config.cacheControl[""]; ok {
+		header.Set("Cache-Control", value)
+	}
+
+	if config.precompressed {
+		header.Add("Vary", "Accept-Encoding")
+
+		for _, precompressed := range precompressedEncodings {
+			if !acceptsEncoding(req, precompressed.encoding) {
+				continue
+			}
+
+			compressed, compressedStat, ok := openStatic(fileSystem, name+precompressed.extension)
+			if !ok {
+				continue
+			}
+			defer compressed.Close()
+			if compressedStat.IsDir() {
+				continue
+			}
+
+			if header.Get("Content-Type") == "" {
+				contentType := mime.TypeByExtension(path.Ext(name))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				header.Set("Content-Type", contentType)
+			}
+			header.Set("Content-Encoding", precompressed.encoding)
+			http.ServeContent(rw, req, stat.Name(), compressedStat.ModTime(), compressed)
+			return
+		}
+	}
+
+	http.ServeContent(rw, req, stat.Name(), stat.ModTime(), file)
+}
+
+func (group *routeGroup) staticMiss(ctx context.Context, rw http.ResponseWriter, req *http.Request, config staticConfig) {
+	if config.notFound {
+		group.notFound(ctx, rw, req)
+		return
+	}
+	http.NotFound(rw, req)
+}
+
+// notFound calls the NotFound handler of the App, the App middleware
+// already ran for the route so only the handler itself is called
+func (group *routeGroup) notFound(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+	if group.app == nil || group.app.currentHandlers().notFoundHandler == nil {
+		defaultNotFoundHandler(ctx, rw, req)
+		return
+	}
+	group.app.currentHandlers().notFoundHandler(ctx, rw, req)
+}
+
+// routeNotFound handles a request the router matched but no handler of the route accepts,
+// like the router does for unknown paths the App middleware and the SPA fallback are used
+func (group *routeGroup) routeNotFound(rw http.ResponseWriter, req *http.Request) {
+	if group.app == nil {
+		defaultNotFoundHandler(context.Background(), rw, req)
+		return
+	}
+	group.app.serveNotFound(rw, req)
+}
+
+// openStatic opens the file and returns its info, the file is closed when it could not be stat
+func openStatic(fileSystem http.FileSystem, name string) (http.File, fs.FileInfo, bool) {
+	file, err := fileSystem.Open(name)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, false
+	}
+	return file, stat, true
+}
+
+func hasDotfile(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding reports if the Accept-Encoding header of the request allows the encoding
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	return acceptsValue(req.Header.Values("Accept-Encoding"), encoding, "*")
+}
+
+// acceptsValue reports if the value, or else the wildcard, is listed with a non zero
+// quality in one of the header values, an empty wildcard is not matched
+func acceptsValue(header []string, value, wildcard string) bool {
+	matched, wildcardQuality := false, 0.0
+	for _, list := range header {
+		for _, part := range strings.Split(list, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			name = strings.TrimSpace(name)
+			exact := strings.EqualFold(name, value)
+			if !exact && (wildcard == "" || name != wildcard) {
+				continue
+			}
+
+			quality := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.TrimSpace(key) == "q" {
+					quality, _ = strconv.ParseFloat(value, 64)
+				}
+			}
+			if exact {
+				return quality > 0
+			}
+			matched, wildcardQuality = true, quality
+		}
+	}
+	return matched && wildcardQuality > 0
+}
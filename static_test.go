@@ -0,0 +1,160 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"testing/fstest"
+)
+
+type StaticSuite struct{}
+
+var _ = Suite(&StaticSuite{})
+
+var testFS = fstest.MapFS{
+	"index.html":      {Data: []byte("index")},
+	"css/site.css":    {Data: []byte("body{}")},
+	"js/app.js":       {Data: []byte("app()")},
+	"js/app.js.gz":    {Data: []byte("gzipped")},
+	"js/app.js.br":    {Data: []byte("brotli")},
+	"docs/readme.md":  {Data: []byte("readme")},
+	"docs/start.html": {Data: []byte("start")},
+	".env":            {Data: []byte("SECRET=1")},
+	".git/config":     {Data: []byte("[core]")},
+}
+
+func (s *StaticSuite) TestStaticFS(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Group("/assets").StaticFS("/", testFS)
+
+	response := doTestRequest(rg, "GET", "/assets/css/site.css")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "body{}")
+	c.Assert(response.Header().Get("Content-Type"), Matches, "text/css.*")
+
+	c.Assert(doTestRequest(rg, "HEAD", "/assets/css/site.css").Code, Equals, 200)
+	c.Assert(doTestRequest(rg, "GET", "/assets/missing.css").Code, Equals, 404)
+}
+
+func (s *StaticSuite) TestFileFS(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.FileFS("/app.js", "js/app.js", testFS)
+	rg.FileFS("/missing.js", "js/missing.js", testFS)
+
+	response := doTestRequest(rg, "GET", "/app.js")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Equals, "app()")
+
+	c.Assert(doTestRequest(rg, "GET", "/missing.js").Code, Equals, 404)
+}
+
+func (s *StaticSuite) TestStaticNotFound(c *C) {
+	app := New()
+	app.NotFound(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte("custom"))
+	})
+	app.StaticFS("/assets", testFS, StaticNotFound())
+	app.FileFS("/missing.js", "js/missing.js", testFS, StaticNotFound())
+	app.Static("/files", "./_test", StaticNotFound())
+
+	for _, path := range []string{"/assets/missing.css", "/missing.js", "/files/missing.txt", "/unknown"} {
+		response := doTestRequest(app, "GET", path)
+		c.Check(response.Code, Equals, 404, Commentf(path))
+		c.Check(response.Body.String(), Equals, "custom", Commentf(path))
+	}
+
+	c.Assert(doTestRequest(app, "GET", "/files/file.txt").Body.String(), Equals, "test file")
+}
+
+func (s *StaticSuite) TestStaticListing(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Group("/public").StaticFS("/", testFS)
+	rg.Group("/hidden").StaticFS("/", testFS, StaticNoListing())
+
+	response := doTestRequest(rg, "GET", "/public/docs/")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Matches, "(?s).*readme.md.*")
+
+	c.Assert(doTestRequest(rg, "GET", "/hidden/docs/").Code, Equals, 404)
+	c.Assert(doTestRequest(rg, "GET", "/hidden/").Body.String(), Equals, "index")
+
+	response = doTestRequest(rg, "GET", "/hidden/docs")
+	c.Assert(response.Code, Equals, 301)
+	c.Assert(response.Header().Get("Location"), Equals, "/hidden/docs/")
+}
+
+func (s *StaticSuite) TestStaticIndex(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.StaticFS("/", testFS, StaticIndex("default.html", "start.html"), StaticNoListing())
+
+	c.Assert(doTestRequest(rg, "GET", "/docs/").Body.String(), Equals, "start")
+	c.Assert(doTestRequest(rg, "GET", "/").Code, Equals, 404)
+
+	rg = newRouteGroup(httprouter.New())
+	rg.StaticFS("/", testFS, StaticIndex())
+
+	response := doTestRequest(rg, "GET", "/")
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(response.Body.String(), Matches, `(?s).*<a href="index.html">index.html</a>.*`)
+}
+
+func (s *StaticSuite) TestStaticCacheControl(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Group("/assets").StaticFS("/", testFS,
+		StaticCacheControl("no-cache"),
+		StaticCacheControl("public, max-age=86400", ".CSS", ".js"),
+	)
+	rg.FileFS("/app.js", "js/app.js", testFS, StaticCacheControl("max-age=60", ".js"))
+
+	c.Assert(doTestRequest(rg, "GET", "/assets/css/site.css").Header().Get("Cache-Control"), Equals, "public, max-age=86400")
+	c.Assert(doTestRequest(rg, "GET", "/assets/docs/readme.md").Header().Get("Cache-Control"), Equals, "no-cache")
+	c.Assert(doTestRequest(rg, "GET", "/assets/").Header().Get("Cache-Control"), Equals, "no-cache")
+	c.Assert(doTestRequest(rg, "GET", "/app.js").Header().Get("Cache-Control"), Equals, "max-age=60")
+}
+
+func (s *StaticSuite) TestStaticPrecompressed(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.StaticFS("/", testFS, StaticPrecompressed())
+
+	response := doTestRequest(rg, "GET", "/js/app.js", map[string]string{"Accept-Encoding": "gzip, br"})
+	c.Assert(response.Body.String(), Equals, "brotli")
+	c.Assert(response.Header().Get("Content-Encoding"), Equals, "br")
+	c.Assert(response.Header().Get("Content-Type"), Matches, ".*javascript.*")
+	c.Assert(response.Header().Get("Vary"), Equals, "Accept-Encoding")
+
+	response = doTestRequest(rg, "GET", "/js/app.js", map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+	c.Assert(response.Body.String(), Equals, "gzipped")
+	c.Assert(response.Header().Get("Content-Encoding"), Equals, "gzip")
+
+	response = doTestRequest(rg, "GET", "/js/app.js", map[string]string{"Accept-Encoding": "*, gzip;q=0, br;q=0"})
+	c.Assert(response.Body.String(), Equals, "app()")
+
+	response = doTestRequest(rg, "GET", "/js/app.js", map[string]string{"Accept-Encoding": "br;q=0, *"})
+	c.Assert(response.Body.String(), Equals, "gzipped")
+
+	response = doTestRequest(rg, "GET", "/js/app.js", map[string]string{"Accept-Encoding": ""})
+	c.Assert(response.Body.String(), Equals, "app()")
+	c.Assert(response.Header().Get("Content-Encoding"), Equals, "")
+
+	response = doTestRequest(rg, "GET", "/css/site.css", map[string]string{"Accept-Encoding": "gzip"})
+	c.Assert(response.Body.String(), Equals, "body{}")
+	c.Assert(response.Header().Get("Content-Encoding"), Equals, "")
+}
+
+func (s *StaticSuite) TestStaticHideDotfiles(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.StaticFS("/", testFS, StaticHideDotfiles())
+
+	c.Assert(doTestRequest(rg, "GET", "/.env").Code, Equals, 404)
+	c.Assert(doTestRequest(rg, "GET", "/.git/config").Code, Equals, 404)
+	c.Assert(doTestRequest(rg, "GET", "/css/site.css").Code, Equals, 200)
+
+	rg = newRouteGroup(httprouter.New())
+	rg.StaticFS("/", testFS, StaticHideDotfiles(), StaticIndex())
+
+	response := doTestRequest(rg, "GET", "/")
+	c.Assert(response.Body.String(), Matches, `(?s).*<a href="css/">css/</a>.*`)
+	c.Assert(response.Body.String(), Not(Matches), `(?s).*\.(env|git).*`)
+}
@@ -0,0 +1,297 @@
+package webapp
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// treeRouter is a Router matching the path segment by segment. Static segments, parameters and
+// catch-all parameters can be mixed on the same position, e.g. /users/new and /users/:id.
+// Static segments take precedence over parameters and parameters over catch-all parameters,
+// when a branch has no route the next one is tried.
+type treeRouter struct {
+	trees   map[string]*treeNode
+	options RouterOptions
+}
+
+type treeNode struct {
+	static   map[string]*treeNode
+	param    *treeNode
+	catchAll *treeRoute
+	route    *treeRoute
+}
+
+type treeRoute struct {
+	path   string
+	names  []string
+	handle RouteHandle
+}
+
+// NewTreeRouter creates a Router supporting static and parameter segments on the same position
+func NewTreeRouter() Router {
+	return &treeRouter{
+		trees:   make(map[string]*treeNode),
+		options: DefaultRouterOptions(),
+	}
+}
+
+func (router *treeRouter) Handle(method, path string, handle RouteHandle) {
+	if len(path) == 0 || path[0] != '/' {
+		panic("path must begin with '/' in path '" + path + "'")
+	}
+	if handle == nil {
+		panic("handle must not be nil")
+	}
+
+	node, ok := router.trees[method]
+	if !ok {
+		node = &treeNode{}
+		router.trees[method] = node
+	}
+
+	route := &treeRoute{path: path, handle: handle}
+	segments := splitPath(path)
+	for i, segment := range segments {
+		if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+			if len(segment) == 1 || strings.ContainsAny(segment[1:], ":*") {
+				panic("wildcards must be named with a non-empty name in path '" + path + "'")
+			}
+			route.names = append(route.names, segment[1:])
+		}
+
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			if i != len(segments)-1 {
+				panic("catch-all routes are only allowed at the end of the path in path '" + path + "'")
+			}
+			if node.catchAll != nil {
+				panic("a handle is already registered for path '" + path + "' conflicting with '" + node.catchAll.path + "'")
+			}
+			node.catchAll = route
+			return
+
+		case strings.HasPrefix(segment, ":"):
+			if node.param == nil {
+				node.param = &treeNode{}
+			}
+			node = node.param
+
+		default:
+			child, ok := node.static[segment]
+			if !ok {
+				if node.static == nil {
+					node.static = make(map[string]*treeNode)
+				}
+				child = &treeNode{}
+				node.static[segment] = child
+			}
+			node = child
+		}
+	}
+
+	if node.route != nil {
+		panic("a handle is already registered for path '" + path + "' conflicting with '" + node.route.path + "'")
+	}
+	node.route = route
+}
+
+func (router *treeRouter) Lookup(method, path string) (RouteHandle, RouteParams, bool) {
+	root, ok := router.trees[method]
+	if !ok {
+		return nil, nil, false
+	}
+
+	if route, values := root.match(splitPath(path), nil); route != nil {
+		return route.handle, route.params(values), false
+	}
+	return nil, nil, root.hasTrailingSlashRoute(path)
+}
+
+func (router *treeRouter) Options() RouterOptions {
+	return router.options
+}
+
+func (router *treeRouter) SetOptions(options RouterOptions) {
+	router.options = options
+}
+
+func (router *treeRouter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+
+	if root, ok := router.trees[req.Method]; ok {
+		if route, values := root.match(splitPath(path), nil); route != nil {
+			route.handle(rw, req, route.params(values))
+			return
+		}
+
+		if req.Method != http.MethodConnect && path != "/" {
+			code := http.StatusMovedPermanently
+			if req.Method != http.MethodGet {
+				code = http.StatusPermanentRedirect
+			}
+
+			if router.options.RedirectTrailingSlash && root.hasTrailingSlashRoute(path) {
+				req.URL.Path = toggleTrailingSlash(path)
+				http.Redirect(rw, req, req.URL.String(), code)
+				return
+			}
+
+			if router.options.RedirectFixedPath {
+				if fixed, ok := root.fixedPath(httprouter.CleanPath(path), router.options.RedirectTrailingSlash); ok {
+					req.URL.Path = fixed
+					http.Redirect(rw, req, req.URL.String(), code)
+					return
+				}
+			}
+		}
+	}
+
+	if req.Method == http.MethodOptions && router.options.HandleOPTIONS {
+		if allow := router.allowed(path, http.MethodOptions); allow != "" {
+			rw.Header().Set("Allow", allow)
+			return
+		}
+	} else if router.options.HandleMethodNotAllowed {
+		if allow := router.allowed(path, req.Method); allow != "" {
+			rw.Header().Set("Allow", allow)
+			if router.options.MethodNotAllowed != nil {
+				router.options.MethodNotAllowed.ServeHTTP(rw, req)
+			} else {
+				http.Error(rw,
+					http.StatusText(http.StatusMethodNotAllowed),
+					http.StatusMethodNotAllowed,
+				)
+			}
+			return
+		}
+	}
+
+	if router.options.NotFound != nil {
+		router.options.NotFound.ServeHTTP(rw, req)
+	} else {
+		http.NotFound(rw, req)
+	}
+}
+
+// allowed returns the methods with a route for the path, OPTIONS is added when there are any
+func (router *treeRouter) allowed(path, requestMethod string) string {
+	var allowed []string
+	segments := splitPath(path)
+	for method, root := range router.trees {
+		if method == requestMethod || method == http.MethodOptions {
+			continue
+		}
+		if route, _ := root.match(segments, nil); route != nil {
+			allowed = append(allowed, method)
+		}
+	}
+
+	if len(allowed) == 0 {
+		return ""
+	}
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+// match returns the route for the segments and the parameter values
+func (node *treeNode) match(segments []string, values []string) (*treeRoute, []string) {
+	if len(segments) == 0 {
+		return node.route, values
+	}
+
+	segment := segments[0]
+	if child, ok := node.static[segment]; ok {
+		if route, matched := child.match(segments[1:], values); route != nil {
+			return route, matched
+		}
+	}
+
+	if node.param != nil && segment != "" {
+		if route, matched := node.param.match(segments[1:], append(values, segment)); route != nil {
+			return route, matched
+		}
+	}
+
+	if node.catchAll != nil {
+		return node.catchAll, append(values, "/"+strings.Join(segments, "/"))
+	}
+	return nil, nil
+}
+
+func (node *treeNode) hasTrailingSlashRoute(path string) bool {
+	if path == "/" {
+		return false
+	}
+	route, _ := node.match(splitPath(toggleTrailingSlash(path)), nil)
+	return route != nil
+}
+
+// fixedPath returns the path of the route matching the path case insensitive
+func (node *treeNode) fixedPath(path string, trailingSlash bool) (string, bool) {
+	if fixed, ok := node.matchFold(splitPath(path), nil); ok {
+		return "/" + strings.Join(fixed, "/"), true
+	}
+	if trailingSlash && path != "/" {
+		if fixed, ok := node.matchFold(splitPath(toggleTrailingSlash(path)), nil); ok {
+			return "/" + strings.Join(fixed, "/"), true
+		}
+	}
+	return "", false
+}
+
+func (node *treeNode) matchFold(segments []string, fixed []string) ([]string, bool) {
+	if len(segments) == 0 {
+		return fixed, node.route != nil
+	}
+
+	segment := segments[0]
+	if child, ok := node.static[segment]; ok {
+		if matched, ok := child.matchFold(segments[1:], append(fixed, segment)); ok {
+			return matched, true
+		}
+	}
+	for key, child := range node.static {
+		if key != segment && strings.EqualFold(key, segment) {
+			if matched, ok := child.matchFold(segments[1:], append(fixed, key)); ok {
+				return matched, true
+			}
+		}
+	}
+
+	if node.param != nil && segment != "" {
+		if matched, ok := node.param.matchFold(segments[1:], append(fixed, segment)); ok {
+			return matched, true
+		}
+	}
+
+	if node.catchAll != nil {
+		return append(fixed, segments...), true
+	}
+	return nil, false
+}
+
+func (route *treeRoute) params(values []string) RouteParams {
+	if len(values) == 0 {
+		return nil
+	}
+
+	params := make(RouteParams, len(values))
+	for i, value := range values {
+		params[i] = RouteParam{Key: route.names[i], Value: value}
+	}
+	return params
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func toggleTrailingSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
@@ -0,0 +1,145 @@
+package webapp
+
+import (
+	"context"
+	. "gopkg.in/check.v1"
+	"net/http"
+)
+
+type TreeRouterSuite struct{}
+
+var _ = Suite(&TreeRouterSuite{})
+
+func paramsHandle(name string) RouteHandle {
+	return func(rw http.ResponseWriter, req *http.Request, params RouteParams) {
+		rw.Write([]byte(name))
+		for _, param := range params {
+			rw.Write([]byte(" " + param.Key + "=" + param.Value))
+		}
+	}
+}
+
+func (s *TreeRouterSuite) TestMatchPriority(c *C) {
+	router := NewTreeRouter()
+	router.Handle("GET", "/", paramsHandle("root"))
+	router.Handle("GET", "/users/new", paramsHandle("new"))
+	router.Handle("GET", "/users/:id", paramsHandle("user"))
+	router.Handle("GET", "/users/:user/posts/:id", paramsHandle("post"))
+	router.Handle("GET", "/users/new/posts", paramsHandle("new posts"))
+	router.Handle("GET", "/files/*filepath", paramsHandle("file"))
+	router.Handle("GET", "/files/readme", paramsHandle("readme"))
+
+	tests := []struct {
+		path     string
+		response string
+	}{
+		{"/", "root"},
+		{"/users/new", "new"},
+		{"/users/12", "user id=12"},
+		{"/users/new/posts/3", "post user=new id=3"},
+		{"/users/new/posts", "new posts"},
+		{"/files/readme", "readme"},
+		{"/files/css/site.css", "file filepath=/css/site.css"},
+	}
+
+	for _, test := range tests {
+		c.Check(doTestRequest(router, "GET", test.path).Body.String(), Equals, test.response, Commentf(test.path))
+	}
+	c.Assert(doTestRequest(router, "GET", "/users").Code, Equals, http.StatusNotFound)
+	c.Assert(doTestRequest(router, "GET", "/files").Code, Equals, http.StatusMovedPermanently)
+}
+
+func (s *TreeRouterSuite) TestLookup(c *C) {
+	router := NewTreeRouter()
+	router.Handle("GET", "/users/:id", paramsHandle("user"))
+	router.Handle("GET", "/groups/", paramsHandle("groups"))
+
+	handle, params, tsr := router.Lookup("GET", "/users/7")
+	c.Assert(handle, NotNil)
+	c.Assert(params.ByName("id"), Equals, "7")
+	c.Assert(tsr, Equals, false)
+
+	handle, _, tsr = router.Lookup("GET", "/groups")
+	c.Assert(handle, IsNil)
+	c.Assert(tsr, Equals, true)
+
+	handle, _, _ = router.Lookup("POST", "/users/7")
+	c.Assert(handle, IsNil)
+}
+
+func (s *TreeRouterSuite) TestRedirects(c *C) {
+	router := NewTreeRouter()
+	router.Handle("GET", "/users/", paramsHandle("users"))
+	router.Handle("GET", "/About", paramsHandle("about"))
+	router.Handle("POST", "/users/:id/", paramsHandle("user"))
+
+	rw := doTestRequest(router, "GET", "/users")
+	c.Assert(rw.Code, Equals, http.StatusMovedPermanently)
+	c.Assert(rw.Header().Get("Location"), Equals, "/users/")
+
+	rw = doTestRequest(router, "POST", "/users/1")
+	c.Assert(rw.Code, Equals, http.StatusPermanentRedirect)
+	c.Assert(rw.Header().Get("Location"), Equals, "/users/1/")
+
+	rw = doTestRequest(router, "GET", "/x/../about")
+	c.Assert(rw.Code, Equals, http.StatusMovedPermanently)
+	c.Assert(rw.Header().Get("Location"), Equals, "/About")
+
+	options := router.Options()
+	options.RedirectTrailingSlash = false
+	options.RedirectFixedPath = false
+	router.SetOptions(options)
+
+	c.Assert(doTestRequest(router, "GET", "/users").Code, Equals, http.StatusNotFound)
+	c.Assert(doTestRequest(router, "GET", "/about").Code, Equals, http.StatusNotFound)
+}
+
+func (s *TreeRouterSuite) TestMethodNotAllowedAndOptions(c *C) {
+	router := NewTreeRouter()
+	router.Handle("GET", "/users/:id", paramsHandle("user"))
+	router.Handle("DELETE", "/users/:id", paramsHandle("delete"))
+
+	rw := doTestRequest(router, "POST", "/users/1")
+	c.Assert(rw.Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(rw.Header().Get("Allow"), Equals, "DELETE, GET, OPTIONS")
+
+	rw = doTestRequest(router, "OPTIONS", "/users/1")
+	c.Assert(rw.Code, Equals, http.StatusOK)
+	c.Assert(rw.Header().Get("Allow"), Equals, "DELETE, GET, OPTIONS")
+
+	options := router.Options()
+	options.HandleMethodNotAllowed = false
+	options.NotFound = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("missing"))
+	})
+	router.SetOptions(options)
+
+	c.Assert(doTestRequest(router, "POST", "/users/1").Body.String(), Equals, "missing")
+}
+
+func (s *TreeRouterSuite) TestInvalidPaths(c *C) {
+	router := NewTreeRouter()
+	router.Handle("GET", "/users/:id", paramsHandle("user"))
+
+	c.Assert(func() { router.Handle("GET", "users", paramsHandle("")) }, PanicMatches, "path must begin with '/' .*")
+	c.Assert(func() { router.Handle("GET", "/files/*path/x", paramsHandle("")) }, PanicMatches, "catch-all routes are only allowed at the end .*")
+	c.Assert(func() { router.Handle("GET", "/users/:name", paramsHandle("")) }, PanicMatches, "a handle is already registered for path '/users/:name' .*")
+	c.Assert(func() { router.Handle("GET", "/users/:", paramsHandle("")) }, PanicMatches, "wildcards must be named .*")
+}
+
+func (s *TreeRouterSuite) TestApp(c *C) {
+	app := NewWithRouter(NewTreeRouter)
+	app.NotFound(writeHandler("missing"))
+	app.GET("/users/new", writeHandler("new"))
+	app.GET("/users/:id", func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("user " + Param(ctx, "id")))
+	})
+	app.Host("api.example.com").GET("/users/:id", writeHandler("api"))
+
+	c.Assert(doTestRequest(app, "GET", "/users/new").Body.String(), Equals, "new")
+	c.Assert(doTestRequest(app, "GET", "/users/3").Body.String(), Equals, "user 3")
+	c.Assert(doTestRequest(app, "GET", "/unknown").Body.String(), Equals, "missing")
+	c.Assert(doTestRequest(app, "POST", "/users/3").Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(doTestRequest(app, "GET", "/users/3", map[string]string{"Host": "api.example.com"}).Body.String(), Equals, "api")
+	c.Assert(doTestRequest(app, "GET", "/unknown", map[string]string{"Host": "api.example.com"}).Body.String(), Equals, "missing")
+}
@@ -0,0 +1,214 @@
+package webapp
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type versionKey int
+
+const apiVersionKey versionKey = iota
+
+// VersionConfig configures how the requested API version is selected
+type VersionConfig struct {
+	// Header is the request header holding the version, e.g. API-Version: 2
+	Header string
+
+	// MediaTypeParam is the parameter of the Accept media type holding
+	// the version, e.g. Accept: application/vnd.example+json;version=2
+	MediaTypeParam string
+
+	// PathPrefix also registers the routes of a version under the version prefix, e.g. /api/v2/users
+	PathPrefix bool
+
+	// Default is the version used when the request has no version,
+	// when empty the first version registered for the route is used
+	Default string
+}
+
+// DefaultVersionConfig returns a configuration selecting the version by the API-Version
+// header, the version parameter of the Accept header or the path prefix
+func DefaultVersionConfig() VersionConfig {
+	return VersionConfig{
+		Header:         "API-Version",
+		MediaTypeParam: "version",
+		PathPrefix:     true,
+	}
+}
+
+type versionScope struct {
+	name   string
+	base   string
+	config VersionConfig
+}
+
+// versionedRoute dispatches the requests for one method and path to the handler of the requested version
+type versionedRoute struct {
+	config   VersionConfig
+	group    *routeGroup
+	versions []string
+	handlers map[string]ContextHandler
+}
+
+// Versioning returns a new group using the configuration for the versions created from it
+func (group *routeGroup) Versioning(config VersionConfig) RouteGroup {
+	versioned := group.With()
+	versioned.(*routeGroup).versioning = &config
+	return versioned
+}
+
+// Version returns a new group for the routes of the API version. Routes with the same method and path
+// in different versions are dispatched on the version requested by the header or Accept media type
+// configured with Versioning, DefaultVersionConfig is used when no configuration is set.
+// Use the Deprecated middleware to mark old versions.
+//
+//     api := app.Group("/api").Versioning(webapp.VersionConfig{Header: "API-Version", Default: "v2"})
+//     api.Version("v1", webapp.Deprecated(deprecatedAt, sunsetAt)).GET("/users", usersV1)
+//     api.Version("v2").GET("/users", usersV2)
+func (group *routeGroup) Version(version string, middleware ...Middleware) RouteGroup {
+	config := DefaultVersionConfig()
+	if group.versioning != nil {
+		config = *group.versioning
+	}
+
+	versioned := group.With(middleware...).(*routeGroup)
+	versioned.version = &versionScope{
+		name:   version,
+		base:   group.path,
+		config: config,
+	}
+	return versioned
+}
+
+// APIVersion returns the version of the route handling the request
+func APIVersion(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	version, _ := ctx.Value(apiVersionKey).(string)
+	return version
+}
+
+// Deprecated is a middleware adding the Deprecation header with the date the routes are
+// deprecated and the Sunset header with the date they will be removed, a zero sunset is omitted
+func Deprecated(since, sunset time.Time) Middleware {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	return func(next ContextHandler) ContextHandler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Deprecation", deprecation)
+			if !sunset.IsZero() {
+				rw.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			next(ctx, rw, req)
+		}
+	}
+}
+
+func (group *routeGroup) handleVersion(route *RouteInfo, handler ContextHandler) {
+	scope := group.version
+	group.addVersion(route, scope, handler)
+	group.routes.add(route)
+
+	if scope.config.PathPrefix {
+		base := strings.TrimSuffix(scope.base, "/")
+		prefixed := *route
+		prefixed.Path = base + "/" + scope.name + strings.TrimPrefix(route.Path, base)
+
+		group.router.Handle(route.Method, prefixed.Path, routeHandle(withAPIVersion(scope.name, handler)))
+		group.routes.add(&prefixed)
+	}
+}
+
+// addVersion adds the handler to the versioned route, the route is registered on the router for the first version
+func (group *routeGroup) addVersion(route *RouteInfo, scope *versionScope, handler ContextHandler) {
+	key := route.Host + " " + route.Method + " " + route.Path
+
+	group.routes.Lock()
+	defer group.routes.Unlock()
+
+	versioned, ok := group.routes.versioned[key]
+	if !ok {
+		versioned = &versionedRoute{
+			config:   scope.config,
+			group:    group,
+			handlers: make(map[string]ContextHandler),
+		}
+		group.router.Handle(route.Method, route.Path, versioned.serve)
+		group.routes.versioned[key] = versioned
+	}
+
+	if _, exists := versioned.handlers[normalizeVersion(scope.name)]; exists {
+		panic("version '" + scope.name + "' is already registered")
+	}
+	versioned.versions = append(versioned.versions, scope.name)
+	versioned.handlers[normalizeVersion(scope.name)] = handler
+}
+
+func (versioned *versionedRoute) serve(rw http.ResponseWriter, req *http.Request, params RouteParams) {
+	ctx := newContextWithParams(context.Background(), withHostParams(req, params).httprouterParams())
+	rw = newResponseWriter(rw)
+
+	if versioned.config.Header != "" {
+		rw.Header().Add("Vary", versioned.config.Header)
+	}
+	if versioned.config.MediaTypeParam != "" {
+		rw.Header().Add("Vary", "Accept")
+	}
+
+	version := versioned.requested(req)
+	if version == "" {
+		version = versioned.config.Default
+	}
+	if version == "" {
+		version = versioned.versions[0]
+	}
+
+	handler, ok := versioned.handlers[normalizeVersion(version)]
+	if !ok {
+		versioned.group.routeNotFound(rw, req)
+		return
+	}
+
+	for _, name := range versioned.versions {
+		if normalizeVersion(name) == normalizeVersion(version) {
+			version = name
+		}
+	}
+	withAPIVersion(version, handler)(ctx, rw, req)
+}
+
+// requested returns the version from the request header or Accept media type
+func (versioned *versionedRoute) requested(req *http.Request) string {
+	if versioned.config.Header != "" {
+		if version := strings.TrimSpace(req.Header.Get(versioned.config.Header)); version != "" {
+			return version
+		}
+	}
+
+	if versioned.config.MediaTypeParam != "" {
+		for _, accept := range req.Header.Values("Accept") {
+			for _, mediaType := range strings.Split(accept, ",") {
+				_, params, err := mime.ParseMediaType(mediaType)
+				if err == nil && params[versioned.config.MediaTypeParam] != "" {
+					return params[versioned.config.MediaTypeParam]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func withAPIVersion(version string, handler ContextHandler) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		handler(context.WithValue(ctx, apiVersionKey, version), rw, req)
+	}
+}
+
+// normalizeVersion makes v2, V2 and 2 the same version
+func normalizeVersion(version string) string {
+	return strings.TrimPrefix(strings.ToLower(version), "v")
+}
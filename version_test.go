@@ -0,0 +1,203 @@
+package webapp
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	. "gopkg.in/check.v1"
+	"net/http"
+	"time"
+)
+
+type VersionSuite struct{}
+
+var _ = Suite(&VersionSuite{})
+
+func versionHandler(body string) ContextHandler {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(body + " " + APIVersion(ctx)))
+	}
+}
+
+func (s *VersionSuite) TestVersion(c *C) {
+	tests := []struct {
+		path     string
+		headers  map[string]string
+		code     int
+		response string
+		vary     []string
+		prepare  func(RouteGroup)
+	}{
+		{
+			// version by header
+			path:     "/api/users/1",
+			headers:  map[string]string{"API-Version": "2"},
+			code:     200,
+			response: "users2 v2",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			path:     "/api/users/1",
+			headers:  map[string]string{"API-Version": "v1"},
+			code:     200,
+			response: "users1 v1",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			path:    "/api/users/1",
+			headers: map[string]string{"API-Version": "3"},
+			code:    404,
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			// the first version without a requested version
+			path:     "/api/users/1",
+			code:     200,
+			response: "users1 v1",
+			vary:     []string{"API-Version", "Accept"},
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			// version by media type
+			path:     "/api/users/1",
+			headers:  map[string]string{"Accept": "text/html, application/vnd.example+json; version=2"},
+			code:     200,
+			response: "users2 v2",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			// version by path prefix
+			path:     "/api/v1/users/1",
+			headers:  map[string]string{"API-Version": "2"},
+			code:     200,
+			response: "users1 v1",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			path:     "/api/v2/users/1",
+			code:     200,
+			response: "users2 v2",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(DefaultVersionConfig())
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			// path prefixes are only routed when configured
+			path: "/api/v2/users/1",
+			code: 404,
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(VersionConfig{Header: "API-Version"})
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			// default version
+			path:     "/api/users/1",
+			code:     200,
+			response: "users2 v2",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(VersionConfig{Header: "X-Version", Default: "v2"})
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		}, {
+			path:     "/api/users/1",
+			headers:  map[string]string{"X-Version": "1"},
+			code:     200,
+			response: "users1 v1",
+			prepare: func(rg RouteGroup) {
+				api := rg.Group("/api").Versioning(VersionConfig{Header: "X-Version", Default: "v2"})
+				api.Version("v1").GET("/users/:id", versionHandler("users1"))
+				api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+			},
+		},
+	}
+
+	for index, test := range tests {
+		rg := newRouteGroup(httprouter.New())
+		test.prepare(rg)
+
+		response := doTestRequest(rg, "GET", test.path, test.headers)
+
+		c.Check(response.Code, Equals, test.code, Commentf("test %d failed", index))
+		if test.response != "" {
+			c.Check(response.Body.String(), Equals, test.response, Commentf("test %d failed", index))
+		}
+		if test.vary != nil {
+			c.Check(response.Header()["Vary"], DeepEquals, test.vary, Commentf("test %d failed", index))
+		}
+	}
+}
+
+func (s *VersionSuite) TestDeprecatedVersion(c *C) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rg := newRouteGroup(httprouter.New())
+	rg.Version("v1", Deprecated(since, sunset)).GET("/", versionHandler("old"))
+	rg.Version("v2").GET("/", versionHandler("new"))
+
+	response := doTestRequest(rg, "GET", "/", map[string]string{"API-Version": "1"})
+	c.Assert(response.Header().Get("Deprecation"), Equals, "@1704067200")
+	c.Assert(response.Header().Get("Sunset"), Equals, "Wed, 01 Jan 2025 00:00:00 GMT")
+
+	response = doTestRequest(rg, "GET", "/v2/")
+	c.Assert(response.Body.String(), Equals, "new v2")
+	c.Assert(response.Header().Get("Deprecation"), Equals, "")
+}
+
+func (s *VersionSuite) TestVersionRoutesAreListed(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	api := rg.Group("/api").Versioning(DefaultVersionConfig())
+	api.Version("v1").GET("/users/:id", versionHandler("users1"))
+	api.Version("v2").Group("/users").GET("/:id", versionHandler("users2"))
+
+	routes := rg.Routes()
+
+	c.Assert(routes, HasLen, 4)
+	c.Assert(routes[0].Path, Equals, "/api/users/:id")
+	c.Assert(routes[0].Version, Equals, "v1")
+	c.Assert(routes[1].Path, Equals, "/api/v1/users/:id")
+	c.Assert(routes[3].Path, Equals, "/api/v2/users/:id")
+	c.Assert(routes[3].Version, Equals, "v2")
+}
+
+func (s *VersionSuite) TestDuplicateVersion(c *C) {
+	rg := newRouteGroup(httprouter.New())
+	rg.Version("v1").GET("/", versionHandler("a"))
+
+	c.Assert(func() { rg.Version("V1").GET("/", versionHandler("b")) }, PanicMatches, "route GET /: version 'V1' is already registered")
+}
+
+func (s *VersionSuite) TestAPIVersionWithoutVersion(c *C) {
+	c.Assert(APIVersion(nil), Equals, "")
+	c.Assert(APIVersion(context.Background()), Equals, "")
+}
+
+func (s *VersionSuite) TestUnknownVersionUsesAppNotFound(c *C) {
+	app := New()
+	app.Use(middlewareWriter("app "))
+	app.NotFound(writeHandler("missing"))
+	app.Group("/api").Versioning(DefaultVersionConfig()).Version("v1").GET("/users/:id", versionHandler("users1"))
+
+	response := doTestRequest(app, "GET", "/api/users/1", map[string]string{"API-Version": "3"})
+	c.Assert(response.Body.String(), Equals, "app missing")
+}
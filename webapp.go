@@ -2,13 +2,17 @@ package webapp
 
 import (
 	"context"
-	"github.com/julienschmidt/httprouter"
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
 type App interface {
 	RouteGroup
 
+	Host(pattern string) RouteGroup
+	Build() error
+
 	MethodNotAllowed(handler ContextHandler)
 	NotFound(handler ContextHandler)
 
@@ -22,24 +26,62 @@ type App interface {
 
 type webapp struct {
 	*routeGroup
-	router *httprouter.Router
+	newRouter func() Router
+
+	// mu serializes the route changes, tree is the current *routeTree
+	mu         sync.Mutex
+	tree       atomic.Value
+	entries    []*routeEntry
+	hostGroups map[string]*routeGroup
+	serving    int32
+
+	// lazy Apps register their routes when built
+	lazy  bool
+	built bool
+
+	// options are the options of the routers in the tree, handlers is the current *appHandlers
+	options  RouterOptions
+	handlers atomic.Value
+}
 
+// appHandlers are the NotFound and MethodNotAllowed handlers of an App, they are replaced as a whole
+type appHandlers struct {
 	notFoundHandler   ContextHandler
 	notAllowedHandler ContextHandler
+
+	// notFound and notAllowed are the handlers wrapped with the middleware of the App
+	notFound   http.Handler
+	notAllowed http.Handler
 }
 
+// New creates an App using the httprouter package for the routes
 func New() App {
-	router := httprouter.New()
+	return NewWithRouter(NewHTTPRouter)
+}
+
+// NewWithRouter creates an App using the routers created by newRouter,
+// a router is created for the App and for every host.
+//
+//     app := webapp.NewWithRouter(webapp.NewTreeRouter)
+//     app.GET("/users/new", newUser)
+//     app.GET("/users/:id", user)
+func NewWithRouter(newRouter func() Router) App {
 	group := &routeGroup{
-		path:   "/",
-		router: router,
+		path: "/",
 	}
-	router.PanicHandler = nil
 
 	app := &webapp{
 		routeGroup: group,
-		router:     router,
+		newRouter:  newRouter,
+		hostGroups: make(map[string]*routeGroup),
+		options:    DefaultRouterOptions(),
 	}
+	group.app = app
+
+	app.options.NotFound = http.HandlerFunc(app.serveNotFound)
+	app.options.MethodNotAllowed = http.HandlerFunc(app.serveMethodNotAllowed)
+	app.handlers.Store(&appHandlers{})
+	app.tree.Store(app.newTree(nil))
 
 	app.HandleOptions(true)
 	app.NotFound(nil)
@@ -53,20 +95,40 @@ func (app *webapp) Use(middleware ...Middleware) {
 	app.routeGroup.Use(middleware...)
 
 	//update handlers
-	app.NotFound(app.notFoundHandler)
-	app.MethodNotAllowed(app.notAllowedHandler)
+	handlers := app.currentHandlers()
+	app.NotFound(handlers.notFoundHandler)
+	app.MethodNotAllowed(handlers.notAllowedHandler)
+}
+
+// SetMiddleware replaces the middleware of the App and rebuilds its routes and handlers
+func (app *webapp) SetMiddleware(middleware ...Middleware) error {
+	if err := app.routeGroup.SetMiddleware(middleware...); err != nil {
+		return err
+	}
+
+	handlers := app.currentHandlers()
+	app.NotFound(handlers.notFoundHandler)
+	app.MethodNotAllowed(handlers.notAllowedHandler)
+	return nil
 }
 
 func (app *webapp) MethodNotAllowed(handler ContextHandler) {
-	app.notAllowedHandler = handler
-	app.router.HandleMethodNotAllowed = handler != nil
-	if !app.router.HandleMethodNotAllowed {
-		return
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	handlers := *app.currentHandlers()
+	handlers.notAllowedHandler = handler
+	handlers.notAllowed = nil
+	if handler != nil {
+		methodNotAllowedHandler := app.routeGroup.middleware.Then(handler)
+		handlers.notAllowed = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			methodNotAllowedHandler(context.Background(), newResponseWriter(rw), req)
+		})
 	}
+	app.handlers.Store(&handlers)
 
-	methodNotAllowedHandler := app.routeGroup.middleware.Then(handler)
-	app.router.MethodNotAllowed = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		methodNotAllowedHandler(context.Background(), newResponseWriter(rw), req)
+	app.setOptions(func(options *RouterOptions) {
+		options.HandleMethodNotAllowed = handler != nil
 	})
 }
 
@@ -74,34 +136,90 @@ func (app *webapp) NotFound(handler ContextHandler) {
 	if handler == nil {
 		handler = defaultNotFoundHandler
 	}
-	app.notFoundHandler = handler
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
 
 	notFoundHandler := app.routeGroup.middleware.Then(handler)
-	app.router.NotFound = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	handlers := *app.currentHandlers()
+	handlers.notFoundHandler = handler
+	handlers.notFound = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		notFoundHandler(context.Background(), newResponseWriter(rw), req)
 	})
+	app.handlers.Store(&handlers)
+}
+
+// currentHandlers returns the NotFound and MethodNotAllowed handlers of the App
+func (app *webapp) currentHandlers() *appHandlers {
+	return app.handlers.Load().(*appHandlers)
+}
+
+func (app *webapp) serveNotFound(rw http.ResponseWriter, req *http.Request) {
+	if app.serveSPA(rw, req) {
+		return
+	}
+	app.currentHandlers().notFound.ServeHTTP(rw, req)
+}
+
+// serveMethodNotAllowed serves the pages of a single page application to browsers,
+// the path of a page can have routes for other methods, e.g. a form posting to it
+func (app *webapp) serveMethodNotAllowed(rw http.ResponseWriter, req *http.Request) {
+	if app.serveSPAPage(rw, req) {
+		return
+	}
+	app.currentHandlers().notAllowed.ServeHTTP(rw, req)
 }
 
 func (app *webapp) RedirectFixedPath(v bool) {
-	app.router.RedirectFixedPath = v
+	app.configure(func(options *RouterOptions) {
+		options.RedirectFixedPath = v
+	})
 }
 
 func (app *webapp) RedirectTrailingSlash(v bool) {
-	app.router.RedirectTrailingSlash = v
+	app.configure(func(options *RouterOptions) {
+		options.RedirectTrailingSlash = v
+	})
 }
 
 func (app *webapp) HandleOptions(v bool) {
-	app.router.HandleOPTIONS = v
+	app.configure(func(options *RouterOptions) {
+		options.HandleOPTIONS = v
+	})
+}
+
+// configure changes the options of the App router and the routers of the hosts
+func (app *webapp) configure(change func(options *RouterOptions)) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	app.setOptions(change)
+}
+
+// setOptions changes the options of the routers, while serving the routers are not changed
+// but replaced by a new tree. The caller must hold the lock.
+func (app *webapp) setOptions(change func(options *RouterOptions)) {
+	change(&app.options)
+	if app.isServing() {
+		if err := app.swap(app.current().hosts, app.entries); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	for _, router := range app.routers() {
+		router.SetOptions(app.options)
+	}
 }
 
 // ListenAndServe starts a HTTP server and sets up a listener on the given host/port.
 func (app *webapp) ListenAndServe(addr string) error {
-	return http.ListenAndServe(addr, app.router)
+	return http.ListenAndServe(addr, app)
 }
 
 // ListenAndServeTLS starts a HTTPS server and sets up a listener on the given host/port.
 func (app *webapp) ListenAndServeTLS(addr, certFile, keyFile string) error {
-	return http.ListenAndServeTLS(addr, certFile, keyFile, app.router)
+	return http.ListenAndServeTLS(addr, certFile, keyFile, app)
 }
 
 func defaultMethodNotAllowedHandler(_ context.Context, rw http.ResponseWriter, _ *http.Request) {